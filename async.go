@@ -0,0 +1,184 @@
+package tinyclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// defaultParallelism is the number of workers of SendAll when BatchOptions.Parallelism isn't set
+const defaultParallelism = 10
+
+// BatchMode tells SendAll what to do when a request fails
+type BatchMode int
+
+// Supported batch modes
+const (
+	//CollectAll sends every request and returns a *BatchError listing the failed ones
+	CollectAll BatchMode = iota
+	//FailFast cancels the requests in flight and skips the rest after the first failure
+	FailFast
+)
+
+// BatchOptions configures SendAll
+type BatchOptions struct {
+	//Parallelism is the number of requests in flight, default is 10
+	Parallelism int
+	Mode        BatchMode
+	//OnProgress is called after every request with the number of completed requests, calls are never concurrent
+	OnProgress func(result BatchResult, completed, total int)
+}
+
+// BatchResult is the result of one request of SendAll, Index is its position in the requests
+type BatchResult struct {
+	Index    int
+	Response *Response
+	Err      error
+}
+
+// BatchError is returned by SendAll in CollectAll mode when some requests failed
+type BatchError struct {
+	//Failed are the indexes of the failed requests in order
+	Failed []int
+	Errs   []error
+	Total  int
+}
+
+func (batchError *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d requests failed, first error: %v", len(batchError.Failed), batchError.Total, batchError.Errs[0])
+}
+
+// Unwrap returns the first error so errors.Is and errors.As work on the batch
+func (batchError *BatchError) Unwrap() error {
+	return batchError.Errs[0]
+}
+
+// Future is the pending result of SendAsync
+type Future struct {
+	done     chan struct{}
+	response *Response
+	err      error
+}
+
+// Done is closed when the result is ready
+func (future *Future) Done() <-chan struct{} {
+	return future.done
+}
+
+// Result waits for the request and returns the results of Send
+func (future *Future) Result() (*Response, error) {
+	<-future.done
+	return future.response, future.err
+}
+
+// SendAsync sends the request in a new goroutine and returns its Future
+func (client *Client) SendAsync(request *Request) *Future {
+	future := &Future{done: make(chan struct{})}
+	go func() {
+		defer close(future.done)
+		future.response, future.err = client.Send(request)
+	}()
+	return future
+}
+
+// SendAll sends requests with bounded parallelism and returns their results in the same order
+// ctx replaces the client context for these requests, requests left when it is done are not sent.
+// Every request has its own context which ends when its response body is closed, bodies can be read after SendAll returns
+func (client *Client) SendAll(ctx context.Context, requests []*Request, options BatchOptions) ([]BatchResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	parallelism := options.Parallelism
+	if parallelism < 1 {
+		parallelism = defaultParallelism
+	}
+
+	results := make([]BatchResult, len(requests))
+	indexes := make(chan int)
+	var mu sync.Mutex
+	var firstErr error
+	completed := 0
+	//inFlight are the cancel funcs of the requests being sent, FailFast cancels them
+	inFlight := map[int]context.CancelFunc{}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < parallelism && worker < len(requests); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				result := BatchResult{Index: index}
+				mu.Lock()
+				if result.Err = ctx.Err(); result.Err == nil && firstErr != nil {
+					result.Err = context.Canceled
+				}
+				var requestCtx context.Context
+				var cancel context.CancelFunc
+				if result.Err == nil {
+					requestCtx, cancel = context.WithCancel(ctx)
+					inFlight[index] = cancel
+				}
+				mu.Unlock()
+
+				if result.Err == nil {
+					result.Response, result.Err = client.sendWithContext(requests[index], requestCtx, cancel)
+				}
+				results[index] = result
+
+				mu.Lock()
+				delete(inFlight, index)
+				completed++
+				if result.Err != nil && options.Mode == FailFast && firstErr == nil {
+					firstErr = fmt.Errorf("request %d: %w", index, result.Err)
+					for _, cancel := range inFlight {
+						cancel()
+					}
+				}
+				if options.OnProgress != nil {
+					options.OnProgress(result, completed, len(requests))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for index := range requests {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return results, firstErr
+	}
+	var batchError *BatchError
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		if batchError == nil {
+			batchError = &BatchError{Total: len(requests)}
+		}
+		batchError.Failed = append(batchError.Failed, result.Index)
+		batchError.Errs = append(batchError.Errs, result.Err)
+	}
+	if batchError != nil {
+		return results, batchError
+	}
+	return results, nil
+}
+
+// sendWithContext sends request with ctx in place of its own context, cancel is called when the response body is closed
+func (client *Client) sendWithContext(request *Request, ctx context.Context, cancel context.CancelFunc) (*Response, error) {
+	previous := request.ctx
+	request.ctx = ctx
+	response, err := client.Send(request)
+	request.ctx = previous
+
+	if response == nil || response.Response == nil || response.Response.Body == nil {
+		cancel()
+		return response, err
+	}
+	response.Response.Body = &cancelOnClose{ReadCloser: response.Response.Body, cancel: cancel}
+	return response, err
+}
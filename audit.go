@@ -0,0 +1,442 @@
+package tinyclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditBatchSize is the size of the buffer which is written at once while records keep coming
+const auditBatchSize = 64 * 1024
+
+// DefaultAuditBodyLength is the default length of the body excerpts in the records
+const DefaultAuditBodyLength = 4 * 1024
+
+// AuditRecord is one request and response exchange, written as a JSON line
+// Binary bodies are base64 encoded and their encoding is set to "base64"
+// Bodies are excerpts, truncated is set when a body was cut and redacted when JSON fields were masked
+type AuditRecord struct {
+	Timestamp             time.Time     `json:"timestamp"`
+	Method                string        `json:"method"`
+	URL                   string        `json:"url"`
+	RequestHeaders        http.Header   `json:"request_headers,omitempty"`
+	RequestBody           string        `json:"request_body,omitempty"`
+	RequestBodyEncoding   string        `json:"request_body_encoding,omitempty"`
+	RequestBodyTruncated  bool          `json:"request_body_truncated,omitempty"`
+	RequestBodyRedacted   bool          `json:"request_body_redacted,omitempty"`
+	Status                int           `json:"status,omitempty"`
+	ResponseHeaders       http.Header   `json:"response_headers,omitempty"`
+	ResponseBody          string        `json:"response_body,omitempty"`
+	ResponseBodyEncoding  string        `json:"response_body_encoding,omitempty"`
+	ResponseBodyTruncated bool          `json:"response_body_truncated,omitempty"`
+	ResponseBodyRedacted  bool          `json:"response_body_redacted,omitempty"`
+	DurationMs            float64       `json:"duration_ms"`
+	Timings               *AuditTimings `json:"timings,omitempty"`
+	Error                 string        `json:"error,omitempty"`
+}
+
+// AuditTimings are the Timings of the response in milliseconds
+type AuditTimings struct {
+	DNSLookup       float64 `json:"dns_lookup_ms"`
+	TCPConnect      float64 `json:"tcp_connect_ms"`
+	TLSHandshake    float64 `json:"tls_handshake_ms"`
+	TimeToFirstByte float64 `json:"ttfb_ms"`
+	BodyTransfer    float64 `json:"body_transfer_ms"`
+	Total           float64 `json:"total_ms"`
+	ConnReused      bool    `json:"conn_reused"`
+}
+
+// AuditSink writes an AuditRecord for every exchange of the client as JSON Lines
+// Records are written by a background goroutine in batches, Close flushes them and must be called on shutdown
+// The response body excerpt is kept while the caller reads Response.Response.Body, nothing is read ahead of the caller
+// The record of a response is written when its body is read to the end or closed
+type AuditSink struct {
+	//Redactor masks the records, nil uses the redactor of the client
+	Redactor *Redactor
+	//MaxBodyLength is the length of the body excerpts, 0 records whole bodies
+	MaxBodyLength int
+	//OnError is called when writing fails, the failed batch is dropped
+	OnError func(err error)
+
+	writer  io.Writer
+	items   chan auditItem
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	pending bytes.Buffer
+}
+
+// auditItem is a record to write or a flush request
+type auditItem struct {
+	record *AuditRecord
+	flush  chan error
+}
+
+// NewAuditSink creates a new AuditSink writing to writer, bufferSize records can be queued before Send waits
+func NewAuditSink(writer io.Writer, bufferSize int) *AuditSink {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	sink := &AuditSink{
+		MaxBodyLength: DefaultAuditBodyLength,
+		writer:        writer,
+		items:         make(chan auditItem, bufferSize),
+		done:          make(chan struct{}),
+	}
+	go sink.run()
+	return sink
+}
+
+// NewAuditFile creates a new AuditSink appending to a RotatingFile
+func NewAuditFile(filename string, maxSize int64, maxBackups int) (*AuditSink, error) {
+	file, err := NewRotatingFile(filename, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuditSink(file, 1024), nil
+}
+
+// SetAuditSink sets the sink receiving a record of every exchange
+func (client *Client) SetAuditSink(sink *AuditSink) *Client {
+	client.auditSink = sink
+	return client
+}
+
+// Write queues record, it is dropped when the sink is closed
+func (sink *AuditSink) Write(record *AuditRecord) {
+	sink.mu.RLock()
+	defer sink.mu.RUnlock()
+	if sink.closed {
+		return
+	}
+	sink.items <- auditItem{record: record}
+}
+
+// Flush waits until the queued records are written
+func (sink *AuditSink) Flush() error {
+	sink.mu.RLock()
+	if sink.closed {
+		sink.mu.RUnlock()
+		return nil
+	}
+	flush := make(chan error, 1)
+	sink.items <- auditItem{flush: flush}
+	sink.mu.RUnlock()
+	return <-flush
+}
+
+// Close writes the queued records and closes the writer when it is an io.Closer
+func (sink *AuditSink) Close() error {
+	sink.mu.Lock()
+	if sink.closed {
+		sink.mu.Unlock()
+		return nil
+	}
+	sink.closed = true
+	close(sink.items)
+	sink.mu.Unlock()
+
+	<-sink.done
+	if closer, ok := sink.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (sink *AuditSink) run() {
+	defer close(sink.done)
+	encoder := json.NewEncoder(&sink.pending)
+	encoder.SetEscapeHTML(false)
+
+	for item := range sink.items {
+		if item.flush != nil {
+			item.flush <- sink.write()
+			continue
+		}
+		if err := encoder.Encode(item.record); err != nil {
+			sink.fail(err)
+			continue
+		}
+		// Batches are written when the queue is empty, so a burst of records is written at once
+		if len(sink.items) == 0 || sink.pending.Len() >= auditBatchSize {
+			sink.write()
+		}
+	}
+	sink.write()
+}
+
+// write writes whole lines only, so a RotatingFile never splits a record
+func (sink *AuditSink) write() error {
+	if sink.pending.Len() == 0 {
+		return nil
+	}
+	_, err := sink.writer.Write(sink.pending.Bytes())
+	sink.pending.Reset()
+	if err != nil {
+		sink.fail(err)
+	}
+	return err
+}
+
+func (sink *AuditSink) fail(err error) {
+	if sink.OnError != nil {
+		sink.OnError(err)
+	}
+}
+
+// audit writes the record of an exchange, response and err are the results of Send
+func (client *Client) audit(request *Request, response *Response, err error) {
+	sink := client.auditSink
+	redactor := sink.Redactor
+	if redactor == nil {
+		redactor = client.redactor
+	}
+
+	record := &AuditRecord{
+		Timestamp: request.SentAt,
+		Method:    string(request.Method),
+		URL:       request.URL,
+	}
+	if httpRequest := request.HttpRequest; httpRequest != nil && httpRequest.URL != nil {
+		record.Method = httpRequest.Method
+		record.URL = redactor.URL(httpRequest.URL)
+		record.RequestHeaders = redactor.Header(httpRequest.Header)
+	}
+	excerpt := auditBody(redactor, request.bodyBytes, true, sink.MaxBodyLength)
+	record.RequestBody, record.RequestBodyEncoding = excerpt.body, excerpt.encoding
+	record.RequestBodyTruncated, record.RequestBodyRedacted = excerpt.truncated, excerpt.redacted
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	if err != nil {
+		record.Error = redactor.Error(err).Error()
+	}
+
+	if response == nil || response.Response == nil {
+		sink.Write(record)
+		return
+	}
+
+	res := response.Response
+	record.Status = res.StatusCode
+	record.ResponseHeaders = redactor.Header(res.Header)
+	write := func(body []byte, complete bool, readErr error) {
+		excerpt := auditBody(redactor, body, complete, sink.MaxBodyLength)
+		record.ResponseBody, record.ResponseBodyEncoding = excerpt.body, excerpt.encoding
+		record.ResponseBodyTruncated, record.ResponseBodyRedacted = excerpt.truncated, excerpt.redacted
+		if readErr != nil && record.Error == "" {
+			record.Error = redactor.Error(fmt.Errorf("can't read http.Response body: %w", readErr)).Error()
+		}
+		record.DurationMs = milliseconds(response.ReceivedAt.Sub(request.SentAt))
+		if response.Request != nil && response.Request.trace != nil {
+			timings := response.Timings()
+			record.Timings = &AuditTimings{
+				DNSLookup:       milliseconds(timings.DNSLookup),
+				TCPConnect:      milliseconds(timings.TCPConnect),
+				TLSHandshake:    milliseconds(timings.TLSHandshake),
+				TimeToFirstByte: milliseconds(timings.TimeToFirstByte),
+				BodyTransfer:    milliseconds(timings.BodyTransfer),
+				Total:           milliseconds(timings.Total),
+				ConnReused:      timings.ConnReused,
+			}
+		}
+		sink.Write(record)
+	}
+
+	if response.bodyBytes != nil || res.Body == nil {
+		if response.bodyBytes != nil {
+			res.Body = ioutil.NopCloser(bytes.NewReader(response.bodyBytes))
+		}
+		write(response.bodyBytes, true, nil)
+		return
+	}
+	res.Body = &auditReader{ReadCloser: res.Body, limit: sink.MaxBodyLength, contentLength: res.ContentLength, done: write}
+}
+
+// auditReader keeps the beginning of a response body while the caller reads it
+// done is called once, when the body ends, fails or is closed
+type auditReader struct {
+	io.ReadCloser
+	limit         int
+	contentLength int64
+	excerpt       bytes.Buffer
+	read          int64
+	once          sync.Once
+	done          func(body []byte, complete bool, readErr error)
+}
+
+func (reader *auditReader) Read(p []byte) (int, error) {
+	n, err := reader.ReadCloser.Read(p)
+	reader.read += int64(n)
+	kept := p[:n]
+	// One byte over the limit tells that the body is longer than the excerpt
+	if room := reader.limit + 1 - reader.excerpt.Len(); reader.limit > 0 && room < len(kept) {
+		kept = kept[:room]
+	}
+	reader.excerpt.Write(kept)
+	switch {
+	case err == io.EOF:
+		reader.finish(true, nil)
+	case err != nil:
+		reader.finish(false, err)
+	}
+	return n, err
+}
+
+// Close writes the record, the body is complete when all of ContentLength was read
+func (reader *auditReader) Close() error {
+	err := reader.ReadCloser.Close()
+	reader.finish(reader.contentLength >= 0 && reader.read == reader.contentLength, nil)
+	return err
+}
+
+func (reader *auditReader) finish(ended bool, readErr error) {
+	reader.once.Do(func() {
+		body := reader.excerpt.Bytes()
+		complete := ended && (reader.limit <= 0 || len(body) <= reader.limit)
+		reader.done(body, complete, readErr)
+	})
+}
+
+// auditedBody is the excerpt of a body in a record
+type auditedBody struct {
+	body      string
+	encoding  string
+	truncated bool
+	redacted  bool
+}
+
+// auditBody masks body and cuts it to limit, body is the beginning of a longer body when complete is false
+func auditBody(redactor *Redactor, body []byte, complete bool, limit int) auditedBody {
+	var excerpt auditedBody
+	if len(body) == 0 {
+		return excerpt
+	}
+	binary := isBinary(body)
+	if !complete && !binary && redactor != nil && len(redactor.JSONFields) > 0 {
+		// The fields of a cut JSON document can't be masked, so none of it is recorded
+		excerpt.truncated, excerpt.redacted = true, true
+		return excerpt
+	}
+	body, excerpt.redacted = redactor.mask(body)
+	if redactor != nil && redactor.MaxBodyLength > 0 && (limit <= 0 || redactor.MaxBodyLength < limit) {
+		limit = redactor.MaxBodyLength
+	}
+	truncated := 0
+	if limit > 0 && len(body) > limit {
+		body, truncated = body[:limit], len(body)-limit
+	}
+	excerpt.truncated = !complete || truncated > 0
+	switch {
+	case binary:
+		excerpt.body, excerpt.encoding = base64.StdEncoding.EncodeToString(body), "base64"
+	case !complete:
+		excerpt.body = fmt.Sprintf("%s... (truncated)", body)
+	case truncated > 0:
+		excerpt.body = fmt.Sprintf("%s... (%d bytes truncated)", body, truncated)
+	default:
+		excerpt.body = string(body)
+	}
+	return excerpt
+}
+
+// RotatingFile is an io.Writer appending to a file which is rotated when it would grow over MaxSize
+// Rotated files are renamed to filename.1, filename.2 and so on, files over MaxBackups are removed
+type RotatingFile struct {
+	Filename   string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens filename for appending, maxSize 0 never rotates
+func NewRotatingFile(filename string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rotating := &RotatingFile{Filename: filename, MaxSize: maxSize, MaxBackups: maxBackups}
+	if err := rotating.open(); err != nil {
+		return nil, err
+	}
+	return rotating, nil
+}
+
+func (rotating *RotatingFile) Write(p []byte) (int, error) {
+	rotating.mu.Lock()
+	defer rotating.mu.Unlock()
+	if rotating.file == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if rotating.MaxSize > 0 && rotating.size > 0 && rotating.size+int64(len(p)) > rotating.MaxSize {
+		if rotateErr = rotating.rotate(); rotating.file == nil {
+			return 0, rotateErr
+		}
+	}
+	n, err := rotating.file.Write(p)
+	rotating.size += int64(n)
+	if err == nil && rotateErr != nil {
+		// p is written to the current file, the error tells that it could not be rotated
+		err = fmt.Errorf("can't rotate %s: %w", rotating.Filename, rotateErr)
+	}
+	return n, err
+}
+
+// Close closes the current file
+func (rotating *RotatingFile) Close() error {
+	rotating.mu.Lock()
+	defer rotating.mu.Unlock()
+	if rotating.file == nil {
+		return nil
+	}
+	err := rotating.file.Close()
+	rotating.file = nil
+	return err
+}
+
+func (rotating *RotatingFile) open() error {
+	file, err := os.OpenFile(rotating.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rotating.file, rotating.size = file, info.Size()
+	return nil
+}
+
+// rotate shifts the backups by one and starts a new file, the lock must be held
+// When the current file can't be renamed it is reopened, so writing goes on
+func (rotating *RotatingFile) rotate() error {
+	if err := rotating.file.Close(); err != nil {
+		return err
+	}
+	rotating.file = nil
+
+	backup := func(n int) string { return fmt.Sprintf("%s.%d", rotating.Filename, n) }
+	if rotating.MaxBackups < 1 {
+		os.Remove(rotating.Filename)
+	} else {
+		os.Remove(backup(rotating.MaxBackups))
+		for n := rotating.MaxBackups - 1; n >= 1; n-- {
+			os.Rename(backup(n), backup(n+1))
+		}
+		if err := os.Rename(rotating.Filename, backup(1)); err != nil {
+			if openErr := rotating.open(); openErr != nil {
+				return openErr
+			}
+			return err
+		}
+	}
+	return rotating.open()
+}
@@ -0,0 +1,229 @@
+package tinyclient
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// APIKeyLocation tells where an APIKey authenticator puts the key
+type APIKeyLocation string
+
+// Supported API key locations
+const (
+	APIKeyInHeader APIKeyLocation = "header"
+	APIKeyInQuery  APIKeyLocation = "query"
+)
+
+// maxRedirects is the same limit used by http.Client when CheckRedirect is nil
+const maxRedirects = 10
+
+// credentialHeaders are always removed when a redirect leaves the original host
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// Authenticator applies credentials to the final *http.Request right before it is sent
+type Authenticator interface {
+	Authenticate(httpRequest *http.Request) error
+}
+
+// Refresher is implemented by authenticators whose credentials can be renewed
+// Send invalidates the credentials and retries the request once when the response is 401 Unauthorized
+type Refresher interface {
+	Authenticator
+	Invalidate(httpRequest *http.Request)
+}
+
+// Challenger is implemented by authenticators which answer a WWW-Authenticate challenge
+// Send retries the request once when Challenge accepts the 401 Unauthorized response
+type Challenger interface {
+	Authenticator
+	Challenge(res *http.Response) bool
+}
+
+// NoAuth can be set on a Request to skip the Authenticator of its Client
+var NoAuth Authenticator = noAuth{}
+
+type noAuth struct{}
+
+func (noAuth) Authenticate(httpRequest *http.Request) error {
+	return nil
+}
+
+// BasicAuth sends username and password as RFC 7617 Basic credentials
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// NewBasicAuth creates a new BasicAuth authenticator
+func NewBasicAuth(username, password string) *BasicAuth {
+	return &BasicAuth{Username: username, Password: password}
+}
+
+func (auth *BasicAuth) Authenticate(httpRequest *http.Request) error {
+	credentials := base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+	httpRequest.Header.Set("Authorization", "Basic "+credentials)
+	return nil
+}
+
+// BearerToken sends a static token as "Authorization: Bearer <token>"
+type BearerToken struct {
+	Token string
+}
+
+// NewBearerToken creates a new BearerToken authenticator
+func NewBearerToken(token string) *BearerToken {
+	return &BearerToken{Token: token}
+}
+
+func (auth *BearerToken) Authenticate(httpRequest *http.Request) error {
+	if auth.Token == "" {
+		return errors.New("bearer token is empty")
+	}
+	httpRequest.Header.Set("Authorization", "Bearer "+auth.Token)
+	return nil
+}
+
+// APIKey sends a static key either as a header or as a query parameter
+type APIKey struct {
+	Name  string
+	Value string
+	In    APIKeyLocation
+}
+
+// NewAPIKey creates a new APIKey authenticator, name is the header or query parameter name
+func NewAPIKey(name, value string, in APIKeyLocation) *APIKey {
+	return &APIKey{Name: name, Value: value, In: in}
+}
+
+func (auth *APIKey) Authenticate(httpRequest *http.Request) error {
+	if auth.Name == "" {
+		return errors.New("api key name is empty")
+	}
+	switch auth.In {
+	case APIKeyInHeader, "":
+		httpRequest.Header.Set(auth.Name, auth.Value)
+	case APIKeyInQuery:
+		query := httpRequest.URL.Query()
+		query.Set(auth.Name, auth.Value)
+		httpRequest.URL.RawQuery = query.Encode()
+	default:
+		return errors.New("unsupported api key location: " + string(auth.In))
+	}
+	return nil
+}
+
+// SetAuthenticator sets the default Authenticator for every request sent by the client
+func (client *Client) SetAuthenticator(authenticator Authenticator) *Client {
+	client.authenticator = authenticator
+	return client
+}
+
+// SetAuthenticator overrides the Authenticator of the client for this request only, use NoAuth to disable it
+func (request *Request) SetAuthenticator(authenticator Authenticator) *Request {
+	request.authenticator = authenticator
+	return request
+}
+
+type authHeadersKey struct{}
+
+// applyCredentials authenticates and then signs the final *http.Request
+func (client *Client) applyCredentials(r *Request) error {
+	if err := client.authenticate(r); err != nil {
+		return err
+	}
+	if client.signer != nil {
+		return client.signer.Sign(r.HttpRequest, r.bodyBytes)
+	}
+	return nil
+}
+
+// requestAuthenticator returns the Authenticator of the request, or of the client when the request has none
+func (client *Client) requestAuthenticator(r *Request) Authenticator {
+	if r.authenticator != nil {
+		return r.authenticator
+	}
+	return client.authenticator
+}
+
+// authenticate applies the request or client Authenticator and remembers which headers it wrote
+// so checkRedirect can strip them when the redirect crosses to a different host
+func (client *Client) authenticate(r *Request) error {
+	authenticator := client.requestAuthenticator(r)
+	if authenticator == nil {
+		return nil
+	}
+
+	before := make(map[string]string, len(r.HttpRequest.Header))
+	for key := range r.HttpRequest.Header {
+		before[key] = r.HttpRequest.Header.Get(key)
+	}
+
+	if err := authenticator.Authenticate(r.HttpRequest); err != nil {
+		return err
+	}
+
+	var written []string
+	for key := range r.HttpRequest.Header {
+		if value, ok := before[key]; !ok || value != r.HttpRequest.Header.Get(key) {
+			written = append(written, key)
+		}
+	}
+	if len(written) > 0 {
+		ctx := context.WithValue(r.HttpRequest.Context(), authHeadersKey{}, written)
+		r.HttpRequest = r.HttpRequest.WithContext(ctx)
+	}
+	return nil
+}
+
+// checkRedirect is used as http.Client.CheckRedirect, it removes credentials when redirected to another host
+func (client *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Host == via[0].URL.Host {
+		return nil
+	}
+	for _, key := range credentialHeaders {
+		req.Header.Del(key)
+	}
+	if written, ok := req.Context().Value(authHeadersKey{}).([]string); ok {
+		for _, key := range written {
+			req.Header.Del(key)
+		}
+	}
+	return nil
+}
+
+// retryUnauthorized sends the request once more when its Authenticator is a Challenger or a Refresher
+// res is returned untouched when the request can't be retried
+func (client *Client) retryUnauthorized(r *Request, res *http.Response) (*http.Response, error) {
+	body, err := r.HttpRequest.GetBody()
+	if err != nil {
+		return res, nil
+	}
+
+	switch authenticator := client.requestAuthenticator(r).(type) {
+	case Challenger:
+		if !authenticator.Challenge(res) {
+			return res, nil
+		}
+	case Refresher:
+		authenticator.Invalidate(r.HttpRequest)
+	default:
+		return res, nil
+	}
+
+	// The first response is dropped, drain it so the connection can be reused
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	r.HttpRequest.Body = body
+	if err := client.applyCredentials(r); err != nil {
+		return nil, err
+	}
+	return client.HTTPClient.Do(r.HttpRequest)
+}
@@ -0,0 +1,240 @@
+package tinyclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Send without sending the request when the circuit of its host or route is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of one circuit
+type CircuitState int
+
+// Circuit states
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker stops sending requests to a failing upstream. It keeps one circuit per key, the host by default.
+// A closed circuit opens when the failure ratio of a window reaches FailureRatio, an open circuit fails fast
+// for CoolDown and then lets HalfOpenRequests trial requests through; they close it again or reopen it
+type CircuitBreaker struct {
+	FailureRatio float64
+	//MinRequests is the number of requests in a window before FailureRatio is checked
+	MinRequests int
+	//Window is how long failures are counted in the closed state
+	Window           time.Duration
+	CoolDown         time.Duration
+	HalfOpenRequests int
+	//KeyFunc selects the circuit of a request, default is KeyByHost
+	KeyFunc func(request *Request) string
+	//IsFailure decides which results count as failures, default is an error or a 5xx response
+	IsFailure func(res *http.Response, err error) bool
+	//OnStateChange is called after a circuit changes its state, changes are also logged by the client Logger
+	OnStateChange func(key string, from, to CircuitState)
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state CircuitState
+	//generation changes with every state change, so results of requests from an older state are ignored
+	generation  uint64
+	windowStart time.Time
+	openedAt    time.Time
+	requests    int
+	failures    int
+	trials      int
+	successes   int
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker keyed by host with a minimum of 10 requests per 1 minute window
+func NewCircuitBreaker(failureRatio float64, coolDown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureRatio:     failureRatio,
+		MinRequests:      10,
+		Window:           time.Minute,
+		CoolDown:         coolDown,
+		HalfOpenRequests: 1,
+		circuits:         map[string]*circuit{},
+	}
+}
+
+// SetCircuitBreaker sets the circuit breaker checked before every request sent over the network
+func (client *Client) SetCircuitBreaker(breaker *CircuitBreaker) *Client {
+	client.circuitBreaker = breaker
+	return client
+}
+
+// State returns the current state of the circuit with key
+func (breaker *CircuitBreaker) State(key string) CircuitState {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if c, ok := breaker.circuits[key]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// allow reserves a request on the circuit of request, done must be called with the result
+func (breaker *CircuitBreaker) allow(client *Client, request *Request) (func(res *http.Response, err error), error) {
+	key := breaker.key(request)
+	now := time.Now()
+
+	breaker.mu.Lock()
+	c := breaker.circuit(key, now)
+	var from CircuitState
+	changed := false
+
+	switch c.state {
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < breaker.CoolDown {
+			breaker.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+		}
+		from, changed = c.state, true
+		breaker.transition(c, CircuitHalfOpen, now)
+		fallthrough
+	case CircuitHalfOpen:
+		if c.trials >= breaker.halfOpenRequests() {
+			breaker.mu.Unlock()
+			breaker.notify(client, key, from, CircuitHalfOpen, changed)
+			return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+		}
+		c.trials++
+	case CircuitClosed:
+		if breaker.Window > 0 && now.Sub(c.windowStart) >= breaker.Window {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+	}
+	generation := c.generation
+	breaker.mu.Unlock()
+	breaker.notify(client, key, from, CircuitHalfOpen, changed)
+
+	return func(res *http.Response, err error) {
+		// Cancelled requests, like the losing attempts of a hedged request, say nothing about the upstream
+		if errors.Is(err, context.Canceled) {
+			breaker.cancel(key, generation)
+			return
+		}
+		breaker.record(client, key, generation, breaker.isFailure(res, err))
+	}, nil
+}
+
+// cancel gives back the half-open trial of a request which was cancelled before it had a result
+func (breaker *CircuitBreaker) cancel(key string, generation uint64) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	c := breaker.circuit(key, time.Now())
+	if c.generation == generation && c.state == CircuitHalfOpen && c.trials > 0 {
+		c.trials--
+	}
+}
+
+func (breaker *CircuitBreaker) record(client *Client, key string, generation uint64, failed bool) {
+	now := time.Now()
+
+	breaker.mu.Lock()
+	c := breaker.circuit(key, now)
+	if c.generation != generation {
+		breaker.mu.Unlock()
+		return
+	}
+
+	from := c.state
+	switch c.state {
+	case CircuitClosed:
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= breaker.MinRequests && float64(c.failures)/float64(c.requests) >= breaker.FailureRatio {
+			breaker.transition(c, CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			breaker.transition(c, CircuitOpen, now)
+		} else if c.successes++; c.successes >= breaker.halfOpenRequests() {
+			breaker.transition(c, CircuitClosed, now)
+		}
+	}
+	to := c.state
+	breaker.mu.Unlock()
+
+	breaker.notify(client, key, from, to, from != to)
+}
+
+// circuit returns the circuit of key, the lock must be held
+func (breaker *CircuitBreaker) circuit(key string, now time.Time) *circuit {
+	if breaker.circuits == nil {
+		breaker.circuits = map[string]*circuit{}
+	}
+	c, ok := breaker.circuits[key]
+	if !ok {
+		c = &circuit{windowStart: now}
+		breaker.circuits[key] = c
+	}
+	return c
+}
+
+// transition changes the state of c and resets its counters, the lock must be held
+func (breaker *CircuitBreaker) transition(c *circuit, state CircuitState, now time.Time) {
+	c.state = state
+	c.generation++
+	c.windowStart, c.requests, c.failures = now, 0, 0
+	c.trials, c.successes = 0, 0
+	if state == CircuitOpen {
+		c.openedAt = now
+	}
+}
+
+func (breaker *CircuitBreaker) notify(client *Client, key string, from, to CircuitState, changed bool) {
+	if !changed {
+		return
+	}
+	client.Logger.Log(LevelInfo, "circuit breaker state changed", Field{Key: "circuit", Value: key}, Field{Key: "from", Value: from}, Field{Key: "to", Value: to})
+	if breaker.OnStateChange != nil {
+		breaker.OnStateChange(key, from, to)
+	}
+}
+
+func (breaker *CircuitBreaker) key(request *Request) string {
+	if breaker.KeyFunc != nil {
+		return breaker.KeyFunc(request)
+	}
+	return KeyByHost(request)
+}
+
+func (breaker *CircuitBreaker) isFailure(res *http.Response, err error) bool {
+	if breaker.IsFailure != nil {
+		return breaker.IsFailure(res, err)
+	}
+	return err != nil || res.StatusCode >= 500
+}
+
+func (breaker *CircuitBreaker) halfOpenRequests() int {
+	if breaker.HalfOpenRequests <= 0 {
+		return 1
+	}
+	return breaker.HalfOpenRequests
+}
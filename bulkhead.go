@@ -0,0 +1,152 @@
+package tinyclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Errors returned by Send when the bulkhead doesn't let a request through
+var (
+	ErrBulkheadFull    = errors.New("bulkhead queue is full")
+	ErrBulkheadTimeout = errors.New("bulkhead queue timeout")
+)
+
+// Bulkhead limits the number of requests in flight. Requests over the limit wait in a bounded queue
+// for at most QueueTimeout. The limit is for the whole client unless KeyFunc splits it, like KeyByHost
+// A request is in flight until its response body is closed
+type Bulkhead struct {
+	MaxInFlight  int
+	MaxQueue     int
+	QueueTimeout time.Duration
+	//KeyFunc selects the compartment of a request, nil means one compartment for the client
+	KeyFunc func(request *Request) string
+
+	mu           sync.Mutex
+	compartments map[string]*compartment
+}
+
+type compartment struct {
+	slots  chan struct{}
+	queued int
+}
+
+// BulkheadStats are the gauges of one compartment
+type BulkheadStats struct {
+	InFlight int
+	Queued   int
+}
+
+// NewBulkhead creates a new Bulkhead for the whole client, queueTimeout 0 means waiting until the request context is done
+func NewBulkhead(maxInFlight, maxQueue int, queueTimeout time.Duration) *Bulkhead {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	return &Bulkhead{
+		MaxInFlight:  maxInFlight,
+		MaxQueue:     maxQueue,
+		QueueTimeout: queueTimeout,
+		compartments: map[string]*compartment{},
+	}
+}
+
+// SetBulkhead sets the bulkhead entered before every request sent over the network
+func (client *Client) SetBulkhead(bulkhead *Bulkhead) *Client {
+	client.bulkhead = bulkhead
+	return client
+}
+
+// Stats returns the in-flight and queued gauges of the compartment with key, key is empty without KeyFunc
+func (bulkhead *Bulkhead) Stats(key string) BulkheadStats {
+	bulkhead.mu.Lock()
+	defer bulkhead.mu.Unlock()
+	c, ok := bulkhead.compartments[key]
+	if !ok {
+		return BulkheadStats{}
+	}
+	return BulkheadStats{InFlight: len(c.slots), Queued: c.queued}
+}
+
+// Keys returns the keys of the compartments, for reporting Stats of every compartment
+func (bulkhead *Bulkhead) Keys() []string {
+	bulkhead.mu.Lock()
+	defer bulkhead.mu.Unlock()
+	keys := make([]string, 0, len(bulkhead.compartments))
+	for key := range bulkhead.compartments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// acquire takes a slot for request or waits for one in the queue, release must be called when the request is done
+// Calling release more than once frees the slot once
+func (bulkhead *Bulkhead) acquire(request *Request) (func(), error) {
+	key := ""
+	if bulkhead.KeyFunc != nil {
+		key = bulkhead.KeyFunc(request)
+	}
+
+	bulkhead.mu.Lock()
+	if bulkhead.compartments == nil {
+		bulkhead.compartments = map[string]*compartment{}
+	}
+	c, ok := bulkhead.compartments[key]
+	if !ok {
+		c = &compartment{slots: make(chan struct{}, bulkhead.MaxInFlight)}
+		bulkhead.compartments[key] = c
+	}
+	var once sync.Once
+	release := func() { once.Do(func() { <-c.slots }) }
+
+	select {
+	case c.slots <- struct{}{}:
+		bulkhead.mu.Unlock()
+		return release, nil
+	default:
+	}
+	if c.queued >= bulkhead.MaxQueue {
+		bulkhead.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d in flight and %d queued", ErrBulkheadFull, len(c.slots), c.queued)
+	}
+	c.queued++
+	bulkhead.mu.Unlock()
+
+	defer func() {
+		bulkhead.mu.Lock()
+		c.queued--
+		bulkhead.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if bulkhead.QueueTimeout > 0 {
+		timer := time.NewTimer(bulkhead.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	ctx := request.HttpRequest.Context()
+
+	select {
+	case c.slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		return nil, fmt.Errorf("%w: waited %v", ErrBulkheadTimeout, bulkhead.QueueTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// releaseOnClose holds the bulkhead slot until the response body is closed, the connection is in use until then
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (body *releaseOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.release()
+	return err
+}
@@ -0,0 +1,533 @@
+package tinyclient
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStorage keeps serialized cache entries, implementations must be safe for concurrent use
+type CacheStorage interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte) error
+	Delete(key string) error
+}
+
+// cacheableStatus are the status codes which are heuristically cacheable in RFC 9111
+var cacheableStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// SetCache enables an RFC 9111 private cache for GET requests, responses are stored in storage
+func (client *Client) SetCache(storage CacheStorage) *Client {
+	if storage == nil {
+		client.cache = nil
+		return client
+	}
+	client.cache = &httpCache{storage: storage, revalidating: map[string]bool{}}
+	return client
+}
+
+type httpCache struct {
+	storage CacheStorage
+
+	mu sync.Mutex
+	//revalidating holds the keys which are revalidated in background because of stale-while-revalidate
+	revalidating map[string]bool
+}
+
+// cacheEntry is a stored response
+type cacheEntry struct {
+	StatusCode   int               `json:"status_code"`
+	Status       string            `json:"status"`
+	Proto        string            `json:"proto"`
+	Header       http.Header       `json:"header"`
+	Body         []byte            `json:"body"`
+	Vary         map[string]string `json:"vary"`
+	RequestTime  time.Time         `json:"request_time"`
+	ResponseTime time.Time         `json:"response_time"`
+}
+
+// cacheControl is a parsed Cache-Control header, directives without a value map to an empty string
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	directives := cacheControl{}
+	for _, value := range header["Cache-Control"] {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, arg := part, ""
+			if eq := strings.Index(part, "="); eq >= 0 {
+				name, arg = part[:eq], strings.Trim(strings.TrimSpace(part[eq+1:]), `"`)
+			}
+			directives[strings.ToLower(strings.TrimSpace(name))] = arg
+		}
+	}
+	return directives
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// seconds returns the delta-seconds argument of directive
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	arg, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// cacheKey is the URL and a hash of the credential headers and jar cookies, responses are never shared between credentials
+// Signed and Digest requests have new credentials on every request, their key is the URL and only public responses are stored
+func (client *Client) cacheKey(request *Request) (key string, publicOnly bool) {
+	key = "GET " + request.HttpRequest.URL.String()
+	authenticator := client.requestAuthenticator(request)
+	if _, ok := authenticator.(Challenger); ok || client.signer != nil {
+		return key, true
+	}
+
+	names := credentialHeaders
+	if apiKey, ok := authenticator.(*APIKey); ok && apiKey.In == APIKeyInHeader {
+		names = append([]string{apiKey.Name}, names...)
+	}
+	hasher := sha256.New()
+	found := false
+	for _, name := range names {
+		for _, value := range request.HttpRequest.Header[http.CanonicalHeaderKey(name)] {
+			hasher.Write([]byte(name + ": " + value + "\n"))
+			found = true
+		}
+	}
+	// Cookies of the jar are added by http.Client after the key is made, they are hashed like the Cookie header
+	if jar := client.HTTPClient.Jar; jar != nil {
+		for _, cookie := range jar.Cookies(request.HttpRequest.URL) {
+			hasher.Write([]byte("Cookie: " + cookie.Name + "=" + cookie.Value + "\n"))
+			found = true
+		}
+	}
+	if found {
+		key += " " + hex.EncodeToString(hasher.Sum(nil))
+	}
+	return key, false
+}
+
+// do serves GET requests from the cache when possible and stores cacheable responses
+// Other methods are sent as is, unsafe methods invalidate the cached response of their URL
+func (cache *httpCache) do(client *Client, request *Request) (*Response, error) {
+	httpRequest := request.HttpRequest
+	requestCC := parseCacheControl(httpRequest.Header)
+	key, publicOnly := client.cacheKey(request)
+
+	if httpRequest.Method != string(Get) || requestCC.has("no-store") {
+		res, err := client.roundTrip(request)
+		if err != nil {
+			return nil, err
+		}
+		if isUnsafeMethod(httpRequest.Method) && res.StatusCode < 400 {
+			cache.storage.Delete(key)
+		}
+		return client.newResponse(request, res), nil
+	}
+
+	entry := cache.load(key)
+	if entry != nil && !entry.varyMatches(httpRequest) {
+		entry = nil
+	}
+
+	var conditional []string
+	if entry != nil {
+		now := time.Now()
+		responseCC := parseCacheControl(entry.Header)
+		age := entry.age(now)
+		lifetime := entry.lifetime(responseCC)
+		revalidate := requestCC.has("no-cache") || responseCC.has("no-cache")
+
+		if !revalidate && age < lifetime {
+			return cache.cachedResponse(client, request, entry, now), nil
+		}
+		if window, ok := responseCC.seconds("stale-while-revalidate"); ok && !revalidate &&
+			!responseCC.has("must-revalidate") && age < lifetime+window {
+			cache.revalidateInBackground(client, request, key, publicOnly, entry)
+			return cache.cachedResponse(client, request, entry, now), nil
+		}
+
+		// Validators of the stored response are only added when the caller didn't set their own
+		if etag := entry.Header.Get("ETag"); etag != "" && httpRequest.Header.Get("If-None-Match") == "" {
+			httpRequest.Header.Set("If-None-Match", etag)
+			conditional = append(conditional, "If-None-Match")
+		}
+		if modified := entry.Header.Get("Last-Modified"); modified != "" && httpRequest.Header.Get("If-Modified-Since") == "" {
+			httpRequest.Header.Set("If-Modified-Since", modified)
+			conditional = append(conditional, "If-Modified-Since")
+		}
+	}
+
+	requestTime := time.Now()
+	res, err := client.roundTrip(request)
+	for _, header := range conditional {
+		httpRequest.Header.Del(header)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conditional) > 0 && res.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		entry.refresh(res, requestTime, time.Now())
+		cache.save(key, entry)
+		return cache.cachedResponse(client, request, entry, time.Now()), nil
+	}
+	// A 304 answers the validators of the caller, the stored response stays as it is
+	if res.StatusCode == http.StatusNotModified {
+		return client.newResponse(request, res), nil
+	}
+
+	if err := cache.store(key, publicOnly, httpRequest, res, requestTime); err != nil {
+		return nil, err
+	}
+	return client.newResponse(request, res), nil
+}
+
+// store buffers the body of res and saves it when the response is cacheable, res.Body stays readable
+// publicOnly stores only responses marked public, for requests whose credentials aren't part of the key
+func (cache *httpCache) store(key string, publicOnly bool, httpRequest *http.Request, res *http.Response, requestTime time.Time) error {
+	responseCC := parseCacheControl(res.Header)
+	requestCC := parseCacheControl(httpRequest.Header)
+	if !cacheableStatus[res.StatusCode] || responseCC.has("no-store") || requestCC.has("no-store") ||
+		strings.TrimSpace(res.Header.Get("Vary")) == "*" || publicOnly && !responseCC.has("public") {
+		cache.storage.Delete(key)
+		return nil
+	}
+
+	entry := &cacheEntry{
+		StatusCode:   res.StatusCode,
+		Status:       res.Status,
+		Proto:        res.Proto,
+		Header:       res.Header.Clone(),
+		RequestTime:  requestTime,
+		ResponseTime: time.Now(),
+	}
+	// A response with neither freshness nor validators can't be reused
+	if entry.lifetime(responseCC) <= 0 && entry.Header.Get("ETag") == "" && entry.Header.Get("Last-Modified") == "" &&
+		!responseCC.has("stale-while-revalidate") {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	entry.Body = body
+
+	entry.Vary = map[string]string{}
+	for _, value := range res.Header["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" {
+				entry.Vary[name] = strings.Join(httpRequest.Header[name], ",")
+			}
+		}
+	}
+
+	cache.save(key, entry)
+	return nil
+}
+
+// revalidateInBackground sends a conditional copy of request which isn't bound to the caller's context
+// It goes through the rate limiter, bulkhead and circuit breaker like every request sent over the network
+func (cache *httpCache) revalidateInBackground(client *Client, request *Request, key string, publicOnly bool, entry *cacheEntry) {
+	cache.mu.Lock()
+	if cache.revalidating[key] {
+		cache.mu.Unlock()
+		return
+	}
+	cache.revalidating[key] = true
+	cache.mu.Unlock()
+
+	clone := request.HttpRequest.Clone(context.Background())
+	clone.Body, clone.ContentLength = nil, 0
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		clone.Header.Set("If-None-Match", etag)
+	}
+	if modified := entry.Header.Get("Last-Modified"); modified != "" {
+		clone.Header.Set("If-Modified-Since", modified)
+	}
+
+	background := *request
+	background.HttpRequest = clone
+	background.ctx = nil
+
+	go func() {
+		defer func() {
+			cache.mu.Lock()
+			delete(cache.revalidating, key)
+			cache.mu.Unlock()
+		}()
+
+		requestTime := time.Now()
+		res, err := client.attempt(&background)
+		if err != nil {
+			client.Logger.Log(LevelWarn, "cache revalidation failed", Field{Key: "url", Value: client.redactor.URL(clone.URL)}, Field{Key: "error", Value: client.redactor.Error(err)})
+			return
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode == http.StatusNotModified {
+			// The entry of the caller may still be read, a fresh copy is refreshed instead
+			if stored := cache.load(key); stored != nil {
+				stored.refresh(res, requestTime, time.Now())
+				cache.save(key, stored)
+			}
+			return
+		}
+		if err := cache.store(key, publicOnly, clone, res, requestTime); err != nil {
+			client.Logger.Log(LevelWarn, "cache revalidation failed", Field{Key: "url", Value: client.redactor.URL(clone.URL)}, Field{Key: "error", Value: client.redactor.Error(err)})
+		}
+	}()
+}
+
+func (cache *httpCache) cachedResponse(client *Client, request *Request, entry *cacheEntry, now time.Time) *Response {
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(entry.age(now)/time.Second), 10))
+	major, minor, ok := http.ParseHTTPVersion(entry.Proto)
+	if !ok {
+		major, minor = 1, 1
+	}
+
+	response := client.newResponse(request, &http.Response{
+		Status:        entry.Status,
+		StatusCode:    entry.StatusCode,
+		Proto:         entry.Proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       request.HttpRequest,
+	})
+	response.FromCache = true
+	return response
+}
+
+func (cache *httpCache) load(key string) *cacheEntry {
+	b, ok := cache.storage.Get(key)
+	if !ok {
+		return nil
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		cache.storage.Delete(key)
+		return nil
+	}
+	return entry
+}
+
+func (cache *httpCache) save(key string, entry *cacheEntry) {
+	if b, err := json.Marshal(entry); err == nil {
+		cache.storage.Set(key, b)
+	}
+}
+
+// varyMatches compares the request headers named by Vary with the ones of the stored request
+func (entry *cacheEntry) varyMatches(httpRequest *http.Request) bool {
+	for name, value := range entry.Vary {
+		if strings.Join(httpRequest.Header[name], ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// age is the current age of RFC 9111 section 4.2.3 without the response delay
+func (entry *cacheEntry) age(now time.Time) time.Duration {
+	var initial time.Duration
+	if seconds, err := strconv.ParseInt(entry.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		initial = time.Duration(seconds) * time.Second
+	}
+	return initial + now.Sub(entry.ResponseTime)
+}
+
+// lifetime is the freshness lifetime from max-age or Expires, there is no heuristic freshness
+func (entry *cacheEntry) lifetime(responseCC cacheControl) time.Duration {
+	if maxAge, ok := responseCC.seconds("max-age"); ok {
+		return maxAge
+	}
+	if expires := entry.Header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(entry.Header.Get("Date"))
+		if err != nil {
+			date = entry.ResponseTime
+		}
+		return expiresAt.Sub(date)
+	}
+	return 0
+}
+
+// refresh updates the stored headers and times with a 304 Not Modified response
+func (entry *cacheEntry) refresh(res *http.Response, requestTime, responseTime time.Time) {
+	for key, values := range res.Header {
+		if key == "Content-Length" {
+			continue
+		}
+		entry.Header[key] = values
+	}
+	entry.Header.Del("Age")
+	entry.RequestTime = requestTime
+	entry.ResponseTime = responseTime
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return false
+	}
+	return true
+}
+
+// MemoryCache is an in-memory CacheStorage which evicts the least recently used entry when it is full
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCache creates a new MemoryCache, maxEntries <= 0 means no limit
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+func (cache *MemoryCache) Get(key string) ([]byte, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	element, ok := cache.items[key]
+	if !ok {
+		return nil, false
+	}
+	cache.ll.MoveToFront(element)
+	return element.Value.(*memoryCacheItem).value, true
+}
+
+func (cache *MemoryCache) Set(key string, value []byte) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if element, ok := cache.items[key]; ok {
+		element.Value.(*memoryCacheItem).value = value
+		cache.ll.MoveToFront(element)
+		return nil
+	}
+	cache.items[key] = cache.ll.PushFront(&memoryCacheItem{key: key, value: value})
+	if cache.maxEntries > 0 && cache.ll.Len() > cache.maxEntries {
+		oldest := cache.ll.Back()
+		cache.ll.Remove(oldest)
+		delete(cache.items, oldest.Value.(*memoryCacheItem).key)
+	}
+	return nil
+}
+
+func (cache *MemoryCache) Delete(key string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if element, ok := cache.items[key]; ok {
+		cache.ll.Remove(element)
+		delete(cache.items, key)
+	}
+	return nil
+}
+
+// Len returns the number of stored entries
+func (cache *MemoryCache) Len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.ll.Len()
+}
+
+// DiskCache is a CacheStorage which keeps one file per entry in a directory
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache creates a new DiskCache, dir is created when it doesn't exist
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (cache *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(cache.dir, hex.EncodeToString(sum[:]))
+}
+
+func (cache *DiskCache) Get(key string) ([]byte, bool) {
+	b, err := ioutil.ReadFile(cache.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// Set writes the entry into a temporary file and renames it, so readers never see a partial entry
+func (cache *DiskCache) Set(key string, value []byte) error {
+	file, err := ioutil.TempFile(cache.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(value); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), cache.path(key))
+}
+
+func (cache *DiskCache) Delete(key string) error {
+	err := os.Remove(cache.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,348 @@
+package tinyclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrInteractionNotFound is returned in CassetteReplayOnly mode when no recorded interaction matches the request
+var ErrInteractionNotFound = errors.New("cassette has no matching interaction")
+
+// CassetteMode tells a Cassette when to send requests to the real transport
+type CassetteMode int
+
+// Supported cassette modes
+const (
+	//CassetteRecord replays matching interactions, other requests are sent and recorded
+	CassetteRecord CassetteMode = iota
+	//CassetteReplayOnly never sends requests, unmatched requests fail with ErrInteractionNotFound
+	CassetteReplayOnly
+	//CassettePassthrough sends every request and records nothing
+	CassettePassthrough
+)
+
+// defaultScrubbedHeaders are never written to a cassette file
+var defaultScrubbedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Amz-Security-Token"}
+
+// defaultScrubbedQueryParams are the credentials of the Redactor and of SigV4 presigned URLs
+var defaultScrubbedQueryParams = append(append([]string{}, defaultRedactedQueryParams...),
+	"X-Amz-Credential", "X-Amz-Security-Token", "X-Amz-Signature")
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is a recorded request, binary bodies are base64 encoded
+type CassetteRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// CassetteResponse is a recorded response, binary bodies are base64 encoded
+type CassetteResponse struct {
+	Status       string      `json:"status"`
+	StatusCode   int         `json:"status_code"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// Matcher tells if a request with body matches a recorded request
+type Matcher func(httpRequest *http.Request, body []byte, recorded CassetteRequest) bool
+
+// MatchMethod matches requests with the same method
+func MatchMethod(httpRequest *http.Request, body []byte, recorded CassetteRequest) bool {
+	return httpRequest.Method == recorded.Method
+}
+
+// MatchURL matches requests with the same URL, including the query with the scrubbed values masked
+func MatchURL(httpRequest *http.Request, body []byte, recorded CassetteRequest) bool {
+	return httpRequest.URL.String() == recorded.URL
+}
+
+// MatchBody matches requests with the same body
+func MatchBody(httpRequest *http.Request, body []byte, recorded CassetteRequest) bool {
+	recordedBody, err := decodeCassetteBody(recorded.Body, recorded.BodyEncoding)
+	return err == nil && bytes.Equal(body, recordedBody)
+}
+
+// MatchHeaders matches requests with the same values of the headers
+func MatchHeaders(headers ...string) Matcher {
+	return func(httpRequest *http.Request, body []byte, recorded CassetteRequest) bool {
+		for _, header := range headers {
+			key := http.CanonicalHeaderKey(header)
+			if strings.Join(httpRequest.Header[key], ",") != strings.Join(recorded.Headers[key], ",") {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Cassette is an http.RoundTripper recording real exchanges to a JSON file and replaying them afterwards
+// Cassette files are JSON only
+// Recorded interactions are replayed in order, the last one is replayed again when all matching ones were used
+type Cassette struct {
+	Path string
+	Mode CassetteMode
+	//Matchers must all match a recorded request, default is MatchMethod and MatchURL
+	Matchers []Matcher
+	//ScrubHeaders are removed before interactions are saved
+	ScrubHeaders []string
+	//ScrubQueryParams are masked in the saved URLs and in the URLs of requests before they are matched, matched case sensitively
+	ScrubQueryParams []string
+	//Transport sends the requests which are not replayed, default is the transport of the client
+	Transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         map[int]bool
+}
+
+// NewCassette creates a new Cassette and loads the interactions of path when it exists
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	cassette := &Cassette{
+		Path:             path,
+		Mode:             mode,
+		Matchers:         []Matcher{MatchMethod, MatchURL},
+		ScrubHeaders:     defaultScrubbedHeaders,
+		ScrubQueryParams: defaultScrubbedQueryParams,
+		used:             map[int]bool{},
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && mode != CassetteReplayOnly {
+		return cassette, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Interactions []*Interaction `json:"interactions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	cassette.interactions = file.Interactions
+	return cassette, nil
+}
+
+// SetMatchers replaces the matchers of recorded requests
+func (cassette *Cassette) SetMatchers(matchers ...Matcher) *Cassette {
+	cassette.Matchers = matchers
+	return cassette
+}
+
+// AddScrubHeaders adds headers which are removed before interactions are saved
+func (cassette *Cassette) AddScrubHeaders(headers ...string) *Cassette {
+	cassette.ScrubHeaders = append(append([]string{}, cassette.ScrubHeaders...), headers...)
+	return cassette
+}
+
+// AddScrubQueryParams adds query parameters which are masked before interactions are saved
+func (cassette *Cassette) AddScrubQueryParams(params ...string) *Cassette {
+	cassette.ScrubQueryParams = append(append([]string{}, cassette.ScrubQueryParams...), params...)
+	return cassette
+}
+
+// SetCassette sends the requests of the client through cassette
+func (client *Client) SetCassette(cassette *Cassette) *Client {
+	if cassette.Transport == nil {
+		cassette.Transport = client.HTTPClient.Transport
+	}
+	client.HTTPClient.Transport = cassette
+	return client
+}
+
+// Interactions returns the recorded interactions
+func (cassette *Cassette) Interactions() []*Interaction {
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	return append([]*Interaction{}, cassette.interactions...)
+}
+
+func (cassette *Cassette) RoundTrip(httpRequest *http.Request) (*http.Response, error) {
+	var body []byte
+	if httpRequest.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(httpRequest.Body)
+		httpRequest.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cassette.Mode != CassettePassthrough {
+		if interaction := cassette.match(httpRequest, body); interaction != nil {
+			return interaction.Response.response(httpRequest)
+		}
+		if cassette.Mode == CassetteReplayOnly {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, httpRequest.Method, cassette.scrubURL(httpRequest.URL))
+		}
+	}
+
+	outgoing := httpRequest.Clone(httpRequest.Context())
+	outgoing.Body = ioutil.NopCloser(bytes.NewReader(body))
+	transport := cassette.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	res, err := transport.RoundTrip(outgoing)
+	if err != nil || cassette.Mode == CassettePassthrough {
+		return res, err
+	}
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	if err := cassette.record(httpRequest, body, res, responseBody); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// match returns the first unused matching interaction, or the last matching one when all were used
+func (cassette *Cassette) match(httpRequest *http.Request, body []byte) *Interaction {
+	// Recorded URLs are scrubbed, so the request is matched with a scrubbed URL too
+	if scrubbed := cassette.scrubURL(httpRequest.URL); scrubbed != httpRequest.URL {
+		httpRequest = httpRequest.Clone(httpRequest.Context())
+		httpRequest.URL = scrubbed
+	}
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	last := -1
+	for i, interaction := range cassette.interactions {
+		if !cassette.matches(httpRequest, body, interaction.Request) {
+			continue
+		}
+		if !cassette.used[i] {
+			cassette.used[i] = true
+			return interaction
+		}
+		last = i
+	}
+	if last >= 0 {
+		return cassette.interactions[last]
+	}
+	return nil
+}
+
+func (cassette *Cassette) matches(httpRequest *http.Request, body []byte, recorded CassetteRequest) bool {
+	for _, matcher := range cassette.Matchers {
+		if !matcher(httpRequest, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// record adds the interaction as used and saves the cassette
+func (cassette *Cassette) record(httpRequest *http.Request, body []byte, res *http.Response, responseBody []byte) error {
+	interaction := &Interaction{
+		Request: CassetteRequest{
+			Method:  httpRequest.Method,
+			URL:     cassette.scrubURL(httpRequest.URL).String(),
+			Headers: cassette.scrub(httpRequest.Header),
+		},
+		Response: CassetteResponse{
+			Status:     res.Status,
+			StatusCode: res.StatusCode,
+			Headers:    cassette.scrub(res.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeCassetteBody(body)
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeCassetteBody(responseBody)
+
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	cassette.interactions = append(cassette.interactions, interaction)
+	cassette.used[len(cassette.interactions)-1] = true
+	return cassette.save()
+}
+
+// save writes the interactions to Path, the lock must be held
+func (cassette *Cassette) save() error {
+	data, err := json.MarshalIndent(map[string]interface{}{"interactions": cassette.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cassette.Path, data, 0644)
+}
+
+func (cassette *Cassette) scrub(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	for _, key := range cassette.ScrubHeaders {
+		scrubbed.Del(key)
+	}
+	return scrubbed
+}
+
+// scrubURL returns u with the values of ScrubQueryParams masked, u itself when nothing is masked
+func (cassette *Cassette) scrubURL(u *url.URL) *url.URL {
+	if u.RawQuery == "" {
+		return u
+	}
+	query := u.Query()
+	changed := false
+	for _, key := range cassette.ScrubQueryParams {
+		if _, ok := query[key]; ok {
+			query[key] = []string{RedactedValue}
+			changed = true
+		}
+	}
+	if !changed {
+		return u
+	}
+	scrubbed := *u
+	scrubbed.RawQuery = strings.Replace(query.Encode(), url.QueryEscape(RedactedValue), RedactedValue, -1)
+	return &scrubbed
+}
+
+func (recorded CassetteResponse) response(httpRequest *http.Request) (*http.Response, error) {
+	body, err := decodeCassetteBody(recorded.Body, recorded.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        recorded.Status,
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Headers.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       httpRequest,
+	}, nil
+}
+
+func encodeCassetteBody(body []byte) (string, string) {
+	if isBinary(body) {
+		return base64.StdEncoding.EncodeToString(body), "base64"
+	}
+	return string(body), ""
+}
+
+func decodeCassetteBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
@@ -4,9 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -15,9 +13,6 @@ import (
 	"os"
 	"regexp"
 	"time"
-
-	"github.com/shirou/gopsutil/cpu"
-	"github.com/shirou/gopsutil/host"
 )
 
 var (
@@ -36,12 +31,40 @@ const (
 )
 
 type Client struct {
-	HTTPClient  *http.Client // The HTTP client to send requests on.
-	Cookies     []*http.Cookie
-	ctx         context.Context
-	InfoLogger  *log.Logger
-	ErrorLogger *log.Logger
-	debugMode   bool
+	HTTPClient *http.Client // The HTTP client to send requests on.
+	Cookies    []*http.Cookie
+	ctx        context.Context
+	Logger     Logger // Receives every log entry, failed requests are logged once by Send.
+	debugMode  bool
+	//authenticator is applied to every request unless the request sets its own
+	authenticator Authenticator
+	//signer signs every request after its credentials are applied
+	signer Signer
+	//headers and queryParams are defaults for every request, hosts are defaults per host
+	headers     http.Header
+	queryParams url.Values
+	hosts       map[string]*hostDefaults
+	//cache stores responses of GET requests when it is set
+	cache *httpCache
+	//versionStrategy is used by Request.IfVersion
+	versionStrategy VersionStrategy
+	//rateLimiter, bulkhead and circuitBreaker are applied in this order before every request sent over the network
+	rateLimiter    *RateLimiter
+	bulkhead       *Bulkhead
+	circuitBreaker *CircuitBreaker
+	//hedgePolicy sends hedged attempts of idempotent requests
+	hedgePolicy *HedgePolicy
+	//coalescer shares one upstream call between identical GET requests in flight
+	coalescer *coalescer
+	//redactor hides sensitive data in the debug output and log entries
+	redactor *Redactor
+	//debugFormatter formats the debug output, nil is BannerFormatter
+	debugFormatter DebugFormatter
+	//userAgent replaces DefaultUserAgent, userAgentSystemInfo appends the probed system info to it
+	userAgent           string
+	userAgentSystemInfo bool
+	//auditSink receives a record of every exchange
+	auditSink *AuditSink
 }
 
 func (client *Client) SetContext(ctx context.Context) *Client {
@@ -55,14 +78,16 @@ func NewClient() *Client {
 	transport.TLSClientConfig = &tls.Config{
 		InsecureSkipVerify: true,
 	}
-	return &Client{
-		InfoLogger:  log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile),
-		ErrorLogger: log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile),
+	client := &Client{
+		Logger:   NewStdLogger(log.New(os.Stderr, "", log.LstdFlags), LevelInfo),
+		redactor: NewRedactor(),
 		HTTPClient: &http.Client{
 			Timeout:   httpClientTimeout,
 			Transport: transport,
 		},
 	}
+	client.HTTPClient.CheckRedirect = client.checkRedirect
+	return client
 }
 
 func (client *Client) SetTimeout(timeout time.Duration) *Client {
@@ -75,7 +100,7 @@ func (client *Client) NewRequest() *Request {
 	return &Request{
 		client:      client,
 		QueryParams: map[string]string{},
-		Headers:     map[string]string{},
+		Headers:     http.Header{},
 		Cookies:     make([]*http.Cookie, 0),
 		HttpRequest: &http.Request{
 			Header: make(http.Header),
@@ -89,98 +114,152 @@ func (client *Client) SetDebugMode(debugMode bool) *Client {
 	return client
 }
 
+// Send sends the request and returns its response, this is the only place where failed requests are logged
 func (client *Client) Send(request *Request) (*Response, error) {
+	response, err := client.execute(request)
+	if client.auditSink != nil {
+		client.audit(request, response, err)
+	}
+	if err != nil {
+		fields := append(client.requestFields(request, response), Field{Key: "error", Value: client.redactor.Error(err)})
+		client.Logger.Log(LevelError, "request failed", fields...)
+		return response, err
+	}
+	client.Logger.Log(LevelDebug, "request completed", client.requestFields(request, response)...)
+	return response, nil
+}
+
+func (client *Client) execute(request *Request) (*Response, error) {
 
 	err := request.parseRequestBody()
 	if err != nil {
-		request.client.ErrorLogger.Println(err)
+		return nil, err
+	}
+	err = client.applyVersion(request)
+	if err != nil {
 		return nil, err
 	}
 	err = client.fillHttpRequest(request)
 	if err != nil {
-		request.client.ErrorLogger.Println(err)
 		return nil, err
 	}
 	if request.HttpRequest.ContentLength > 0 && request.HttpRequest.GetBody == nil {
 		err := errors.New("request.GetBody cannot be nil because it prevents redirection when content length>0")
-		client.ErrorLogger.Println(err)
 		return nil, err
 	}
 
+	var exchange *DebugExchange
 	if client.debugMode {
-		var headerString string
-		if headerBytes, err := json.Marshal(request.HttpRequest.Header); err != nil {
-			headerString = "Could not Marshal Request Headers"
-			client.ErrorLogger.Println(err)
+		exchange = client.debugRequest(request)
+	}
+
+	response, err := client.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.debugMode {
+		if err := client.debugResponse(exchange, request, response); err != nil {
 			return nil, err
-		} else {
-			headerString = string(headerBytes)
 		}
+	}
 
-		requestLogString :=
-			"\n==============================================================================\n" +
-				"~~~ HTTP REQUEST ~~~\n" +
-				fmt.Sprintf("%s  %s\n", request.HttpRequest.Method, request.HttpRequest.URL) +
-				fmt.Sprintf("HOST   : %s\n", request.HttpRequest.URL.Host) +
-				fmt.Sprintf("HEADERS:\n%s\n", headerString) +
-				fmt.Sprintf("BODY   :\n%v\n", string(request.bodyBytes)) +
-				"------------------------------------------------------------------------------\n"
-		client.InfoLogger.Printf(requestLogString)
+	if err := client.preconditionError(response); err != nil {
+		return response, err
 	}
 
-	res, err := client.HTTPClient.Do(request.HttpRequest)
+	return response, nil
+}
 
+// do sends the filled request, coalesced with identical GET requests in flight when coalescing is set
+func (client *Client) do(request *Request) (*Response, error) {
+	// SentAt and the trace are set again when the request goes to the network
+	request.SentAt = time.Now()
+	request.trace = nil
+	if client.coalescer != nil && request.Method == Get {
+		return client.coalescer.do(client, request)
+	}
+	return client.exchange(request)
+}
+
+// exchange sends the filled request, through the cache when it is set
+func (client *Client) exchange(request *Request) (*Response, error) {
+	if client.cache != nil {
+		return client.cache.do(client, request)
+	}
+	res, err := client.roundTrip(request)
 	if err != nil {
-		client.ErrorLogger.Println(err)
 		return nil, err
 	}
+	return client.newResponse(request, res), nil
+}
 
-	response := &Response{
-		client:     client,
-		Response:   res,
-		Request:    request,
-		ReceivedAt: time.Now(),
+// roundTrip sends the http request over the network, hedged when the client has a hedge policy
+func (client *Client) roundTrip(request *Request) (*http.Response, error) {
+	if client.hedgePolicy != nil && hedgeable(request) {
+		return client.hedge(request)
 	}
+	return client.attempt(request)
+}
 
-	if client.debugMode {
-		var elapsedDuration time.Duration
-		elapsedDuration = response.ReceivedAt.Sub(request.SentAt)
-
-		var responseHeaderString string
-		if headerBytes, err := json.Marshal(res.Header); err != nil {
-			responseHeaderString = "Could not Marshal Req Headers"
-			client.ErrorLogger.Println(err)
+// attempt sends the http request once, after the rate limiter and bulkhead and guarded by the circuit breaker
+func (client *Client) attempt(request *Request) (res *http.Response, err error) {
+	if client.rateLimiter != nil {
+		if err := client.rateLimiter.wait(request); err != nil {
 			return nil, err
-		} else {
-			responseHeaderString = string(headerBytes)
 		}
-
-		responseBytes, err := response.ReadBody()
-		if err != nil {
-			client.ErrorLogger.Println(err)
-			return nil, err
+		defer func() { client.rateLimiter.observe(request, res) }()
+	}
+	if client.bulkhead != nil {
+		release, acquireErr := client.bulkhead.acquire(request)
+		if acquireErr != nil {
+			return nil, acquireErr
 		}
+		// The slot is held until the response body is closed
+		defer func() {
+			if err != nil || res.Body == nil {
+				release()
+				return
+			}
+			res.Body = &releaseOnClose{ReadCloser: res.Body, release: release}
+		}()
+	}
+	if client.circuitBreaker != nil {
+		done, allowErr := client.circuitBreaker.allow(client, request)
+		if allowErr != nil {
+			return nil, allowErr
+		}
+		// done gets the result of send, err must not be shadowed here
+		defer func() { done(res, err) }()
+	}
+	return client.send(request)
+}
 
-		responseLogString :=
-			"\n==============================================================================\n" +
-				"~~~ HTTP RESPONSE ~~~\n" +
-				fmt.Sprintf("STATUS       : %s\n", res.Status) +
-				fmt.Sprintf("PROTO        : %s\n", res.Proto) +
-				fmt.Sprintf("RECEIVED AT  : %v\n", response.ReceivedAt) +
-				fmt.Sprintf("TIME DURATION: %v\n", elapsedDuration) +
-				fmt.Sprintf("RESPONSE BODY: %v\n", string(responseBytes)) +
-				fmt.Sprintf("HEADERS:\n%s\n", responseHeaderString) +
-				"------------------------------------------------------------------------------\n"
-
-		client.InfoLogger.Printf(responseLogString)
+// send sends the http request and retries it once when a 401 response can be answered by the authenticator
+func (client *Client) send(request *Request) (*http.Response, error) {
+	trace := request.traceRequest()
+	res, err := client.HTTPClient.Do(request.HttpRequest)
+	if err == nil && res.StatusCode == http.StatusUnauthorized {
+		res, err = client.retryUnauthorized(request, res)
 	}
+	if err == nil {
+		res.Body = trace.body(res.Body)
+	}
+	return res, err
+}
 
-	return response, nil
+func (client *Client) newResponse(request *Request, res *http.Response) *Response {
+	return &Response{
+		client:     client,
+		Response:   res,
+		Request:    request,
+		ReceivedAt: time.Now(),
+		Attempt:    request.hedgeAttempt,
+	}
 }
 
 func (client *Client) fillHttpRequest(r *Request) (err error) {
 
-	r.SentAt = time.Now()
 	//Set request Body
 	r.HttpRequest.Body = ioutil.NopCloser(bytes.NewReader(r.bodyBytes))
 
@@ -189,16 +268,23 @@ func (client *Client) fillHttpRequest(r *Request) (err error) {
 	// Set request URL
 	URL, err := r.generateURL()
 	if err != nil {
-		client.ErrorLogger.Println(err)
 		return err
 	}
 	r.HttpRequest.URL = URL
 
 	// Set request method
 	r.HttpRequest.Method = string(r.Method)
-	// Add headers into http request
-	for key, value := range r.Headers {
-		r.HttpRequest.Header.Set(key, value)
+	// Add client and host defaults beneath the request headers and query params
+	client.applyDefaults(r)
+
+	// Add headers into http request, they replace defaults with the same key
+	for key := range r.Headers {
+		r.HttpRequest.Header.Del(key)
+	}
+	for key, values := range r.Headers {
+		for _, value := range values {
+			r.HttpRequest.Header.Add(key, value)
+		}
 	}
 
 	// Add cookies from client instance into http request
@@ -215,25 +301,14 @@ func (client *Client) fillHttpRequest(r *Request) (err error) {
 	if client.ctx != nil {
 		r.HttpRequest = r.HttpRequest.WithContext(client.ctx)
 	}
-
-	if client.debugMode {
-		hostStat, _ := host.Info()
-		cpuStat, _ := cpu.Info()
-
-		infoString := fmt.Sprintf("%v/%v; %v; %v; %v",
-			ClientName,
-			ClientVersion,
-			hostStat.Platform,
-			cpuStat[0].ModelName,
-			hostStat.Hostname,
-		)
-		if err == nil {
-			r.HttpRequest.Header.Set("User-Agent", infoString)
-		}
+	if r.ctx != nil {
+		r.HttpRequest = r.HttpRequest.WithContext(r.ctx)
 	}
 
+	// Set the User-Agent unless a header already did
+	client.applyUserAgent(r)
+
 	if err != nil {
-		client.ErrorLogger.Println(err)
 		return err
 	}
 
@@ -245,6 +320,11 @@ func (client *Client) fillHttpRequest(r *Request) (err error) {
 		return nil, nil
 	}
 
+	// Apply credentials and signatures after all headers and cookies are set
+	if err = client.applyCredentials(r); err != nil {
+		return err
+	}
+
 	return
 }
 
@@ -0,0 +1,123 @@
+package tinyclient
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// coalescedHeaders are always part of the coalescing key so credentials of different callers are never mixed
+var coalescedHeaders = []string{"Authorization", "Cookie"}
+
+// coalescer executes identical GET requests in flight once and shares the response
+type coalescer struct {
+	headers []string
+	mu      sync.Mutex
+	calls   map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done     chan struct{}
+	response *Response
+	body     []byte
+	err      error
+	//abandoned is set when the context of the leading caller was done, its error isn't shared
+	abandoned bool
+}
+
+// SetCoalescing makes identical GET requests in flight share one upstream call
+// Requests are identical when their final URL and the given headers are equal, Authorization and Cookie are always compared
+// Every caller gets its own Response with the buffered body
+func (client *Client) SetCoalescing(headers ...string) *Client {
+	client.coalescer = &coalescer{
+		headers: append(append([]string{}, coalescedHeaders...), headers...),
+		calls:   map[string]*coalescedCall{},
+	}
+	return client
+}
+
+func (coalescer *coalescer) key(httpRequest *http.Request) string {
+	var builder strings.Builder
+	builder.WriteString(httpRequest.Method + " " + httpRequest.URL.String())
+	for _, header := range coalescer.headers {
+		builder.WriteString("\n" + http.CanonicalHeaderKey(header) + ": " + strings.Join(httpRequest.Header[http.CanonicalHeaderKey(header)], ","))
+	}
+	return builder.String()
+}
+
+// do sends the request unless an identical one is in flight, then it waits for that one
+// When the leading caller gives up, the waiters whose context is still live send the request again
+func (coalescer *coalescer) do(client *Client, request *Request) (*Response, error) {
+	key := coalescer.key(request.HttpRequest)
+	ctx := request.HttpRequest.Context()
+
+	coalescer.mu.Lock()
+	for {
+		call, ok := coalescer.calls[key]
+		if !ok {
+			break
+		}
+		coalescer.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err == nil {
+			response := call.share(client, request)
+			response.Coalesced = true
+			return response, nil
+		}
+		if !call.abandoned || ctx.Err() != nil {
+			return nil, call.err
+		}
+		coalescer.mu.Lock()
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	coalescer.calls[key] = call
+	coalescer.mu.Unlock()
+
+	call.response, call.body, call.err = client.bufferedExchange(request)
+	call.abandoned = call.err != nil && ctx.Err() != nil
+
+	coalescer.mu.Lock()
+	delete(coalescer.calls, key)
+	coalescer.mu.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.share(client, request), nil
+}
+
+// bufferedExchange sends the request and reads its whole body so it can be shared
+func (client *Client) bufferedExchange(request *Request) (*Response, []byte, error) {
+	response, err := client.exchange(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	body := response.bodyBytes
+	if body == nil && response.Response.Body != nil {
+		body, err = ioutil.ReadAll(response.Response.Body)
+		response.Response.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return response, body, nil
+}
+
+// share returns an independent copy of the response of the call for request
+func (call *coalescedCall) share(client *Client, request *Request) *Response {
+	res := *call.response.Response
+	res.Header = call.response.Response.Header.Clone()
+	res.Body = ioutil.NopCloser(bytes.NewReader(call.body))
+
+	response := client.newResponse(request, &res)
+	response.FromCache = call.response.FromCache
+	response.Attempt = call.response.Attempt
+	return response
+}
@@ -0,0 +1,157 @@
+package tinyclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Errors returned by Send together with the response when a request with a precondition is rejected
+// Use errors.Is to check them
+var (
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrConflict           = errors.New("conflict")
+)
+
+// VersionStrategy writes the expected version of a resource into a request before it is sent
+type VersionStrategy interface {
+	ApplyVersion(request *Request, version interface{}) error
+}
+
+// defaultVersionStrategy sends the version as the "version" query param, like the Form3 account API
+var defaultVersionStrategy VersionStrategy = VersionInQuery("version")
+
+// VersionInQuery sends the version as a query param
+type VersionInQuery string
+
+func (param VersionInQuery) ApplyVersion(request *Request, version interface{}) error {
+	request.QueryParams[string(param)] = fmt.Sprint(version)
+	return nil
+}
+
+// VersionInBody sets the version into the JSON body at the path of field names, like VersionInBody{"data", "version"}
+// Missing objects on the path are created, the body must be a JSON object
+type VersionInBody []string
+
+func (path VersionInBody) ApplyVersion(request *Request, version interface{}) error {
+	if len(path) == 0 {
+		return errors.New("version body path is empty")
+	}
+	if len(request.bodyBytes) == 0 {
+		return errors.New("version can't be set into the body: body is empty")
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(request.bodyBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return fmt.Errorf("version can't be set into the body: %v", err)
+	}
+	if decoder.More() {
+		return errors.New("version can't be set into the body: body has data after the JSON object")
+	}
+	body, ok := document.(map[string]interface{})
+	if !ok {
+		return errors.New("version can't be set into the body: body is not a JSON object")
+	}
+
+	object := body
+	for _, field := range path[:len(path)-1] {
+		child, ok := object[field].(map[string]interface{})
+		if !ok {
+			if object[field] != nil {
+				return fmt.Errorf("version can't be set into the body: %q is not an object", field)
+			}
+			child = map[string]interface{}{}
+			object[field] = child
+		}
+		object = child
+	}
+	object[path[len(path)-1]] = version
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request.bodyBytes = b
+	return nil
+}
+
+// SetVersionStrategy sets how IfVersion sends the version for requests of the client, default is the "version" query param
+func (client *Client) SetVersionStrategy(strategy VersionStrategy) *Client {
+	client.versionStrategy = strategy
+	return client
+}
+
+// SetVersionStrategy overrides the VersionStrategy of the client for this request
+func (request *Request) SetVersionStrategy(strategy VersionStrategy) *Request {
+	request.versionStrategy = strategy
+	return request
+}
+
+// IfVersion sends the expected version of the resource, a 409 Conflict or 412 Precondition Failed response is returned as an error
+func (request *Request) IfVersion(version interface{}) *Request {
+	request.version = version
+	request.precondition = true
+	return request
+}
+
+// IfMatch sends If-Match, the request is applied only when the resource still has etag
+func (request *Request) IfMatch(etag string) *Request {
+	request.Headers.Set("If-Match", etag)
+	request.precondition = true
+	return request
+}
+
+// IfNoneMatch sends If-None-Match, use "*" to create a resource only when it doesn't exist
+func (request *Request) IfNoneMatch(etag string) *Request {
+	request.Headers.Set("If-None-Match", etag)
+	request.precondition = true
+	return request
+}
+
+// IfUnmodifiedSince sends If-Unmodified-Since, the request is applied only when the resource wasn't modified after t
+func (request *Request) IfUnmodifiedSince(t time.Time) *Request {
+	request.Headers.Set("If-Unmodified-Since", t.UTC().Format(http.TimeFormat))
+	request.precondition = true
+	return request
+}
+
+// ETag returns the ETag header of the response
+func (response *Response) ETag() string {
+	return response.Response.Header.Get("ETag")
+}
+
+// applyVersion writes the version of IfVersion with the request or client VersionStrategy
+func (client *Client) applyVersion(request *Request) error {
+	if request.version == nil {
+		return nil
+	}
+	strategy := defaultVersionStrategy
+	if client.versionStrategy != nil {
+		strategy = client.versionStrategy
+	}
+	if request.versionStrategy != nil {
+		strategy = request.versionStrategy
+	}
+	return strategy.ApplyVersion(request, request.version)
+}
+
+// preconditionError returns ErrPreconditionFailed or ErrConflict for a rejected request with a precondition
+// The URL in the message is redacted, credentials in the query never reach the error
+func (client *Client) preconditionError(response *Response) error {
+	if !response.Request.precondition {
+		return nil
+	}
+	httpRequest := response.Request.HttpRequest
+	switch response.Response.StatusCode {
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf("%w: %s %s", ErrPreconditionFailed, httpRequest.Method, client.redactor.URL(httpRequest.URL))
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s %s", ErrConflict, httpRequest.Method, client.redactor.URL(httpRequest.URL))
+	}
+	return nil
+}
@@ -0,0 +1,449 @@
+package tinyclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieFileFormat is the file format used by CookieJar.Save and CookieJar.Load
+type CookieFileFormat string
+
+// Supported cookie file formats
+const (
+	CookieFormatJSON     CookieFileFormat = "json"
+	CookieFormatNetscape CookieFileFormat = "netscape"
+)
+
+const netscapeHeader = "# Netscape HTTP Cookie File"
+
+// CookieJar is an http.CookieJar which applies domain, path, secure and expiry rules of RFC 6265
+// and can be persisted to a file, inspected and cleared per domain
+type CookieJar struct {
+	//PublicSuffixList rejects Domain attributes which are public suffixes like co.uk, without it only top level domains are rejected
+	PublicSuffixList cookiejar.PublicSuffixList
+
+	mu sync.Mutex
+	//entries are keyed by domain and then by name and path
+	entries map[string]map[string]*jarEntry
+}
+
+// jarEntry is a stored cookie, it is also the JSON file format
+type jarEntry struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	HostOnly bool      `json:"host_only"`
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"http_only"`
+	Expires  time.Time `json:"expires"`
+	Created  time.Time `json:"created"`
+}
+
+// NewCookieJar creates a new empty CookieJar
+func NewCookieJar() *CookieJar {
+	return &CookieJar{entries: map[string]map[string]*jarEntry{}}
+}
+
+// SetPublicSuffixList sets the public suffix list, like golang.org/x/net/publicsuffix.List
+func (jar *CookieJar) SetPublicSuffixList(list cookiejar.PublicSuffixList) *CookieJar {
+	jar.PublicSuffixList = list
+	return jar
+}
+
+// SetCookieJar sets the cookie jar of HTTPClient, response cookies are stored in it and sent back to matching hosts
+func (client *Client) SetCookieJar(jar http.CookieJar) *Client {
+	client.HTTPClient.Jar = jar
+	return client
+}
+
+// SetCookies stores the cookies received from u
+func (jar *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := canonicalHost(u.Hostname())
+	if host == "" {
+		return
+	}
+	now := time.Now()
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	for _, cookie := range cookies {
+		entry := &jarEntry{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			Created:  now,
+		}
+
+		domain := canonicalHost(strings.TrimPrefix(cookie.Domain, "."))
+		hostOnly := domain == ""
+		if domain != "" && jar.isPublicSuffix(domain) {
+			// A public suffix is only accepted from the host itself, as a host only cookie like net/http/cookiejar does
+			if domain != host {
+				continue
+			}
+			hostOnly = true
+		}
+		if domain == "" || domain == host {
+			entry.Domain, entry.HostOnly = host, hostOnly
+		} else if !domainMatch(host, domain) || net.ParseIP(host) != nil || !strings.Contains(domain, ".") {
+			// Domain attribute for another site, an IP address or a top level domain is rejected
+			continue
+		} else {
+			entry.Domain = domain
+		}
+
+		if entry.Path == "" || !strings.HasPrefix(entry.Path, "/") {
+			entry.Path = defaultCookiePath(u.Path)
+		}
+
+		if cookie.MaxAge < 0 {
+			entry.Expires = now.Add(-time.Second)
+		} else if cookie.MaxAge > 0 {
+			entry.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		} else if !cookie.Expires.IsZero() {
+			entry.Expires = cookie.Expires
+		}
+
+		jar.store(entry, now)
+	}
+}
+
+// isPublicSuffix reports whether domain is a public suffix, cookies for it would be sent to every site under it
+func (jar *CookieJar) isPublicSuffix(domain string) bool {
+	if jar.PublicSuffixList == nil || net.ParseIP(domain) != nil {
+		return false
+	}
+	suffix := jar.PublicSuffixList.PublicSuffix(domain)
+	return suffix != "" && !strings.HasSuffix(domain, "."+suffix)
+}
+
+// store adds or replaces entry, expired entries delete the stored cookie
+func (jar *CookieJar) store(entry *jarEntry, now time.Time) {
+	key := entry.Name + ";" + entry.Path
+	domainEntries := jar.entries[entry.Domain]
+
+	if !entry.Expires.IsZero() && !entry.Expires.After(now) {
+		delete(domainEntries, key)
+		return
+	}
+	if domainEntries == nil {
+		domainEntries = map[string]*jarEntry{}
+		jar.entries[entry.Domain] = domainEntries
+	}
+	if old, ok := domainEntries[key]; ok {
+		entry.Created = old.Created
+	}
+	domainEntries[key] = entry
+}
+
+// Cookies returns the cookies to send to u, longer paths first
+func (jar *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	host := canonicalHost(u.Hostname())
+	if host == "" {
+		return nil
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	secure := u.Scheme == "https"
+	now := time.Now()
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	var selected []*jarEntry
+	for domain, domainEntries := range jar.entries {
+		if !domainMatch(host, domain) {
+			continue
+		}
+		for key, entry := range domainEntries {
+			if !entry.Expires.IsZero() && !entry.Expires.After(now) {
+				delete(domainEntries, key)
+				continue
+			}
+			if (entry.HostOnly && host != domain) || (entry.Secure && !secure) || !pathMatch(path, entry.Path) {
+				continue
+			}
+			selected = append(selected, entry)
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		if len(selected[i].Path) != len(selected[j].Path) {
+			return len(selected[i].Path) > len(selected[j].Path)
+		}
+		return selected[i].Created.Before(selected[j].Created)
+	})
+
+	cookies := make([]*http.Cookie, len(selected))
+	for i, entry := range selected {
+		cookies[i] = &http.Cookie{Name: entry.Name, Value: entry.Value}
+	}
+	return cookies
+}
+
+// DomainCookies returns every stored cookie whose domain is domain, with all of their attributes
+func (jar *CookieJar) DomainCookies(domain string) []*http.Cookie {
+	domain = canonicalHost(strings.TrimPrefix(domain, "."))
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	var cookies []*http.Cookie
+	for _, entry := range jar.entries[domain] {
+		cookies = append(cookies, entry.cookie())
+	}
+	sort.Slice(cookies, func(i, j int) bool {
+		return cookies[i].Name+cookies[i].Path < cookies[j].Name+cookies[j].Path
+	})
+	return cookies
+}
+
+// Domains returns the domains which have stored cookies
+func (jar *CookieJar) Domains() []string {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	domains := make([]string, 0, len(jar.entries))
+	for domain, domainEntries := range jar.entries {
+		if len(domainEntries) > 0 {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Clear removes the cookies of domain, or every cookie when domain is empty
+func (jar *CookieJar) Clear(domain string) {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	if domain == "" {
+		jar.entries = map[string]map[string]*jarEntry{}
+		return
+	}
+	delete(jar.entries, canonicalHost(strings.TrimPrefix(domain, ".")))
+}
+
+// Save writes the cookies which are not expired to filename
+func (jar *CookieJar) Save(filename string, format CookieFileFormat) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := jar.Export(file, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load reads cookies from filename and adds them to the jar, expired cookies are skipped
+func (jar *CookieJar) Load(filename string, format CookieFileFormat) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return jar.Import(file, format)
+}
+
+// Export writes the cookies which are not expired to w
+func (jar *CookieJar) Export(w io.Writer, format CookieFileFormat) error {
+	entries := jar.snapshot()
+
+	switch format {
+	case CookieFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case CookieFormatNetscape:
+		buffered := bufio.NewWriter(w)
+		fmt.Fprintln(buffered, netscapeHeader)
+		for _, entry := range entries {
+			domain := entry.Domain
+			if !entry.HostOnly {
+				domain = "." + domain
+			}
+			if entry.HttpOnly {
+				domain = "#HttpOnly_" + domain
+			}
+			var expires int64
+			if !entry.Expires.IsZero() {
+				expires = entry.Expires.Unix()
+			}
+			fmt.Fprintf(buffered, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, netscapeBool(!entry.HostOnly), entry.Path,
+				netscapeBool(entry.Secure), expires, entry.Name, entry.Value)
+		}
+		return buffered.Flush()
+	}
+	return errors.New("unsupported cookie file format: " + string(format))
+}
+
+// Import reads cookies from r and adds them to the jar, expired cookies are skipped
+func (jar *CookieJar) Import(r io.Reader, format CookieFileFormat) error {
+	var entries []*jarEntry
+
+	switch format {
+	case CookieFormatJSON:
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return err
+		}
+	case CookieFormatNetscape:
+		scanner := bufio.NewScanner(r)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			httpOnly := strings.HasPrefix(text, "#HttpOnly_")
+			text = strings.TrimPrefix(text, "#HttpOnly_")
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			fields := strings.Split(text, "\t")
+			if len(fields) != 7 {
+				return fmt.Errorf("cookie file line %d has %d fields instead of 7", line, len(fields))
+			}
+			expires, err := strconv.ParseInt(fields[4], 10, 64)
+			if err != nil {
+				return fmt.Errorf("cookie file line %d: %v", line, err)
+			}
+			entry := &jarEntry{
+				Domain:   canonicalHost(strings.TrimPrefix(fields[0], ".")),
+				HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+				Path:     fields[2],
+				Secure:   strings.EqualFold(fields[3], "TRUE"),
+				HttpOnly: httpOnly,
+				Name:     fields[5],
+				Value:    fields[6],
+			}
+			if expires > 0 {
+				entry.Expires = time.Unix(expires, 0)
+			}
+			entries = append(entries, entry)
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	default:
+		return errors.New("unsupported cookie file format: " + string(format))
+	}
+
+	now := time.Now()
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+	for _, entry := range entries {
+		if entry.Domain == "" || entry.Name == "" {
+			continue
+		}
+		if entry.Path == "" {
+			entry.Path = "/"
+		}
+		if entry.Created.IsZero() {
+			entry.Created = now
+		}
+		jar.store(entry, now)
+	}
+	return nil
+}
+
+// snapshot returns copies of the stored entries which are not expired, ordered by domain, path and name
+func (jar *CookieJar) snapshot() []*jarEntry {
+	now := time.Now()
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	entries := []*jarEntry{}
+	for _, domainEntries := range jar.entries {
+		for _, entry := range domainEntries {
+			if entry.Expires.IsZero() || entry.Expires.After(now) {
+				copied := *entry
+				entries = append(entries, &copied)
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Domain != b.Domain {
+			return a.Domain < b.Domain
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Name < b.Name
+	})
+	return entries
+}
+
+func (entry *jarEntry) cookie() *http.Cookie {
+	domain := entry.Domain
+	if entry.HostOnly {
+		domain = ""
+	}
+	return &http.Cookie{
+		Name:     entry.Name,
+		Value:    entry.Value,
+		Domain:   domain,
+		Path:     entry.Path,
+		Expires:  entry.Expires,
+		Secure:   entry.Secure,
+		HttpOnly: entry.HttpOnly,
+	}
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func canonicalHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// domainMatch reports whether host is domain or a subdomain of it
+func domainMatch(host, domain string) bool {
+	return host == domain || (strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil)
+}
+
+// pathMatch implements the path-match rule of RFC 6265 section 5.1.4
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath implements the default-path rule of RFC 6265 section 5.1.4
+func defaultCookiePath(requestPath string) string {
+	if requestPath == "" || requestPath[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(requestPath, "/")
+	if i == 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
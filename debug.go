@@ -0,0 +1,383 @@
+package tinyclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ANSI escape codes used by ColorFormatter
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorBlue   = "\033[34m"
+	colorCyan   = "\033[36m"
+	colorGray   = "\033[90m"
+)
+
+// DebugFormatter formats the debug output of an exchange, an empty string logs nothing
+// FormatRequest is called before the request is sent and FormatResponse after its body was read
+type DebugFormatter interface {
+	FormatRequest(exchange *DebugExchange) string
+	FormatResponse(exchange *DebugExchange) string
+}
+
+// DebugWriter is implemented by formatters whose output is written to DebugOutput as is instead of being logged
+// Loggers add a prefix and fields to every entry, which breaks machine readable output like JSON lines
+type DebugWriter interface {
+	DebugOutput() io.Writer
+}
+
+// DebugBody is a request or response body after redaction
+type DebugBody struct {
+	Bytes       []byte
+	ContentType string
+	//Binary bodies are printed as hex dumps
+	Binary bool
+	//Truncated is the number of bytes cut by the MaxBodyLength of the redactor
+	Truncated int
+}
+
+// DebugExchange is what debug mode prints for one request, the response fields are set after the response is received
+type DebugExchange struct {
+	Method        string
+	URL           string
+	Host          string
+	RequestHeader http.Header
+	RequestBody   DebugBody
+	SentAt        time.Time
+
+	Status         string
+	StatusCode     int
+	Proto          string
+	ResponseHeader http.Header
+	ResponseBody   DebugBody
+	ReceivedAt     time.Time
+	Duration       time.Duration
+	Timings        Timings
+}
+
+// SetDebugFormatter sets the format of the debug output, default is BannerFormatter
+func (client *Client) SetDebugFormatter(formatter DebugFormatter) *Client {
+	client.debugFormatter = formatter
+	return client
+}
+
+// debugRequest logs the request in debug mode and returns its exchange for debugResponse
+func (client *Client) debugRequest(request *Request) *DebugExchange {
+	httpRequest := request.HttpRequest
+	exchange := &DebugExchange{
+		Method:        httpRequest.Method,
+		URL:           client.redactor.URL(httpRequest.URL),
+		Host:          httpRequest.URL.Host,
+		RequestHeader: client.redactor.Header(httpRequest.Header),
+		RequestBody:   client.debugBody(request.bodyBytes, httpRequest.Header.Get(ContentType)),
+		SentAt:        request.SentAt,
+	}
+	if text := client.formatter().FormatRequest(exchange); text != "" {
+		client.debugOutput(text, client.requestFields(request, nil))
+	}
+	return exchange
+}
+
+// debugResponse reads the response body and logs the response in debug mode
+func (client *Client) debugResponse(exchange *DebugExchange, request *Request, response *Response) error {
+	body, err := response.ReadBody()
+	if err != nil {
+		return err
+	}
+	res := response.Response
+	exchange.Status = res.Status
+	exchange.StatusCode = res.StatusCode
+	exchange.Proto = res.Proto
+	exchange.ResponseHeader = client.redactor.Header(res.Header)
+	exchange.ResponseBody = client.debugBody(body, res.Header.Get(ContentType))
+	exchange.SentAt = request.SentAt
+	exchange.ReceivedAt = response.ReceivedAt
+	exchange.Duration = response.ReceivedAt.Sub(request.SentAt)
+	exchange.Timings = response.Timings()
+
+	if text := client.formatter().FormatResponse(exchange); text != "" {
+		client.debugOutput(text, client.requestFields(request, response))
+	}
+	return nil
+}
+
+// debugOutput logs text, or writes it as a line when the formatter is a DebugWriter
+func (client *Client) debugOutput(text string, fields []Field) {
+	if writer, ok := client.formatter().(DebugWriter); ok {
+		if output := writer.DebugOutput(); output != nil {
+			io.WriteString(output, text+"\n")
+			return
+		}
+	}
+	client.Logger.Log(LevelInfo, text, fields...)
+}
+
+func (client *Client) formatter() DebugFormatter {
+	if client.debugFormatter != nil {
+		return client.debugFormatter
+	}
+	return BannerFormatter{}
+}
+
+func (client *Client) debugBody(body []byte, contentType string) DebugBody {
+	masked, truncated := client.redactor.body(body)
+	return DebugBody{
+		Bytes:       masked,
+		ContentType: contentType,
+		Binary:      isBinary(body),
+		Truncated:   truncated,
+	}
+}
+
+// isBinary tells if body can't be printed as text
+func isBinary(body []byte) bool {
+	return !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0
+}
+
+// String returns the body as text, binary bodies as a hex dump and JSON bodies indented when indent is true
+func (body DebugBody) String(indent bool) string {
+	var text string
+	switch {
+	case body.Binary:
+		text = strings.TrimSuffix(hex.Dump(body.Bytes), "\n")
+	case indent && len(body.Bytes) > 0 && body.Truncated == 0 && json.Valid(body.Bytes):
+		var buffer bytes.Buffer
+		json.Indent(&buffer, body.Bytes, "", "  ")
+		text = buffer.String()
+	default:
+		text = string(body.Bytes)
+	}
+	if body.Truncated > 0 {
+		text += fmt.Sprintf("... (%d bytes truncated)", body.Truncated)
+	}
+	return text
+}
+
+// BannerFormatter is the default debug output with a banner per request and response and headers as JSON
+// Pretty indents the headers and JSON bodies
+type BannerFormatter struct {
+	Pretty bool
+}
+
+func (formatter BannerFormatter) FormatRequest(exchange *DebugExchange) string {
+	return "\n==============================================================================\n" +
+		"~~~ HTTP REQUEST ~~~\n" +
+		fmt.Sprintf("%s  %s\n", exchange.Method, exchange.URL) +
+		fmt.Sprintf("HOST   : %s\n", exchange.Host) +
+		fmt.Sprintf("HEADERS:\n%s\n", formatter.header(exchange.RequestHeader)) +
+		fmt.Sprintf("BODY   :\n%v\n", exchange.RequestBody.String(formatter.Pretty)) +
+		"------------------------------------------------------------------------------\n"
+}
+
+func (formatter BannerFormatter) FormatResponse(exchange *DebugExchange) string {
+	timings := exchange.Timings
+	return "\n==============================================================================\n" +
+		"~~~ HTTP RESPONSE ~~~\n" +
+		fmt.Sprintf("STATUS       : %s\n", exchange.Status) +
+		fmt.Sprintf("PROTO        : %s\n", exchange.Proto) +
+		fmt.Sprintf("RECEIVED AT  : %v\n", exchange.ReceivedAt) +
+		fmt.Sprintf("TIME DURATION: %v\n", exchange.Duration) +
+		fmt.Sprintf("DNS LOOKUP   : %v\n", timings.DNSLookup) +
+		fmt.Sprintf("TCP CONNECT  : %v\n", timings.TCPConnect) +
+		fmt.Sprintf("TLS HANDSHAKE: %v\n", timings.TLSHandshake) +
+		fmt.Sprintf("FIRST BYTE   : %v\n", timings.TimeToFirstByte) +
+		fmt.Sprintf("BODY TRANSFER: %v\n", timings.BodyTransfer) +
+		fmt.Sprintf("TOTAL        : %v\n", timings.Total) +
+		fmt.Sprintf("CONN REUSED  : %v\n", timings.ConnReused) +
+		fmt.Sprintf("RESPONSE BODY: %v\n", exchange.ResponseBody.String(formatter.Pretty)) +
+		fmt.Sprintf("HEADERS:\n%s\n", formatter.header(exchange.ResponseHeader)) +
+		"------------------------------------------------------------------------------\n"
+}
+
+func (formatter BannerFormatter) header(header http.Header) string {
+	var headerBytes []byte
+	if formatter.Pretty {
+		headerBytes, _ = json.MarshalIndent(header, "", "  ")
+	} else {
+		headerBytes, _ = json.Marshal(header)
+	}
+	return string(headerBytes)
+}
+
+// WireFormatter prints requests and responses as they look on the wire in HTTP/1.1, like httputil.DumpRequestOut
+// Pretty indents JSON bodies
+type WireFormatter struct {
+	Pretty bool
+}
+
+func (formatter WireFormatter) FormatRequest(exchange *DebugExchange) string {
+	return wireRequest(exchange, formatter.Pretty, false)
+}
+
+func (formatter WireFormatter) FormatResponse(exchange *DebugExchange) string {
+	return wireResponse(exchange, formatter.Pretty, false)
+}
+
+// ColorFormatter is the wire format colored for terminals, JSON bodies are indented
+type ColorFormatter struct{}
+
+func (ColorFormatter) FormatRequest(exchange *DebugExchange) string {
+	return wireRequest(exchange, true, true)
+}
+
+func (ColorFormatter) FormatResponse(exchange *DebugExchange) string {
+	return wireResponse(exchange, true, true)
+}
+
+func wireRequest(exchange *DebugExchange, indent, color bool) string {
+	requestURI := exchange.URL
+	if i := strings.Index(requestURI, exchange.Host); exchange.Host != "" && i >= 0 {
+		requestURI = requestURI[i+len(exchange.Host):]
+	}
+	if requestURI == "" {
+		requestURI = "/"
+	}
+	line := fmt.Sprintf("%s %s HTTP/1.1", exchange.Method, requestURI)
+	if color {
+		line = colorBold + colorBlue + line + colorReset
+	}
+	header := exchange.RequestHeader.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Host", exchange.Host)
+	return "\n" + line + "\r\n" + wireMessage(header, exchange.RequestBody, indent, color)
+}
+
+func wireResponse(exchange *DebugExchange, indent, color bool) string {
+	line := fmt.Sprintf("%s %s", exchange.Proto, exchange.Status)
+	if color {
+		line = colorBold + statusColor(exchange.StatusCode) + line + colorReset +
+			colorGray + fmt.Sprintf(" (%v)", exchange.Duration) + colorReset
+	}
+	return "\n" + line + "\r\n" + wireMessage(exchange.ResponseHeader, exchange.ResponseBody, indent, color)
+}
+
+// wireMessage writes sorted headers, an empty line and the body
+func wireMessage(header http.Header, body DebugBody, indent, color bool) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	for _, key := range keys {
+		name := key
+		if color {
+			name = colorCyan + key + colorReset
+		}
+		for _, value := range header[key] {
+			builder.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	builder.WriteString("\r\n")
+	text := body.String(indent)
+	if color && body.Binary {
+		text = colorGray + text + colorReset
+	}
+	builder.WriteString(text)
+	return builder.String()
+}
+
+func statusColor(statusCode int) string {
+	switch {
+	case statusCode >= 500:
+		return colorRed
+	case statusCode >= 400:
+		return colorYellow
+	case statusCode >= 300:
+		return colorCyan
+	}
+	return colorGreen
+}
+
+// JSONFormatter writes one single line JSON record per exchange after the response to Writer, os.Stderr when it is nil
+// The Logger is bypassed so every line is valid JSON. Text bodies are strings, JSON bodies are embedded as JSON and binary bodies are base64
+type JSONFormatter struct {
+	Writer io.Writer
+}
+
+// DebugOutput returns the writer of the JSON lines
+func (formatter JSONFormatter) DebugOutput() io.Writer {
+	if formatter.Writer != nil {
+		return formatter.Writer
+	}
+	return os.Stderr
+}
+
+type jsonExchange struct {
+	Method          string          `json:"method"`
+	URL             string          `json:"url"`
+	RequestHeaders  http.Header     `json:"request_headers"`
+	RequestBody     json.RawMessage `json:"request_body,omitempty"`
+	Status          int             `json:"status"`
+	Proto           string          `json:"proto"`
+	ResponseHeaders http.Header     `json:"response_headers"`
+	ResponseBody    json.RawMessage `json:"response_body,omitempty"`
+	SentAt          time.Time       `json:"sent_at"`
+	DurationMs      float64         `json:"duration_ms"`
+	TimeToFirstByte float64         `json:"ttfb_ms"`
+	ConnReused      bool            `json:"conn_reused"`
+}
+
+func (JSONFormatter) FormatRequest(exchange *DebugExchange) string {
+	return ""
+}
+
+func (JSONFormatter) FormatResponse(exchange *DebugExchange) string {
+	record := jsonExchange{
+		Method:          exchange.Method,
+		URL:             exchange.URL,
+		RequestHeaders:  exchange.RequestHeader,
+		RequestBody:     jsonBody(exchange.RequestBody),
+		Status:          exchange.StatusCode,
+		Proto:           exchange.Proto,
+		ResponseHeaders: exchange.ResponseHeader,
+		ResponseBody:    jsonBody(exchange.ResponseBody),
+		SentAt:          exchange.SentAt,
+		DurationMs:      milliseconds(exchange.Duration),
+		TimeToFirstByte: milliseconds(exchange.Timings.TimeToFirstByte),
+		ConnReused:      exchange.Timings.ConnReused,
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func jsonBody(body DebugBody) json.RawMessage {
+	if len(body.Bytes) == 0 {
+		return nil
+	}
+	if !body.Binary && body.Truncated == 0 && json.Valid(body.Bytes) {
+		var buffer bytes.Buffer
+		if json.Compact(&buffer, body.Bytes) == nil {
+			return buffer.Bytes()
+		}
+	}
+	var value interface{} = body.String(false)
+	if body.Binary {
+		value = body.Bytes
+	}
+	b, _ := json.Marshal(value)
+	return b
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
@@ -0,0 +1,140 @@
+package tinyclient
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hostDefaults are headers and query params sent only to one host
+type hostDefaults struct {
+	headers     http.Header
+	queryParams url.Values
+}
+
+// Default headers and query params are merged into every request in this order, later ones win:
+// client defaults, host defaults of the request host, request Headers and QueryParams.
+// A request drops an inherited default with RemoveHeader or RemoveQueryParam.
+
+// SetHeader sets a header sent with every request of the client
+func (client *Client) SetHeader(header, value string) *Client {
+	if client.headers == nil {
+		client.headers = http.Header{}
+	}
+	client.headers.Set(header, value)
+	return client
+}
+
+// SetQueryParam sets a query param sent with every request of the client
+func (client *Client) SetQueryParam(param, value string) *Client {
+	if client.queryParams == nil {
+		client.queryParams = url.Values{}
+	}
+	client.queryParams.Set(param, value)
+	return client
+}
+
+// SetHostHeader sets a header sent with every request to host, host can be "example.com" or "example.com:8080"
+func (client *Client) SetHostHeader(host, header, value string) *Client {
+	client.hostDefaults(host).headers.Set(header, value)
+	return client
+}
+
+// SetHostQueryParam sets a query param sent with every request to host, host can be "example.com" or "example.com:8080"
+func (client *Client) SetHostQueryParam(host, param, value string) *Client {
+	client.hostDefaults(host).queryParams.Set(param, value)
+	return client
+}
+
+func (client *Client) hostDefaults(host string) *hostDefaults {
+	if client.hosts == nil {
+		client.hosts = map[string]*hostDefaults{}
+	}
+	host = strings.ToLower(host)
+	defaults, ok := client.hosts[host]
+	if !ok {
+		defaults = &hostDefaults{headers: http.Header{}, queryParams: url.Values{}}
+		client.hosts[host] = defaults
+	}
+	return defaults
+}
+
+// matchingHostDefaults returns the defaults of hostname and then of host:port, so the more specific one is applied last
+func (client *Client) matchingHostDefaults(u *url.URL) []*hostDefaults {
+	var matching []*hostDefaults
+	if defaults, ok := client.hosts[strings.ToLower(u.Hostname())]; ok {
+		matching = append(matching, defaults)
+	}
+	if u.Port() != "" {
+		if defaults, ok := client.hosts[strings.ToLower(u.Host)]; ok {
+			matching = append(matching, defaults)
+		}
+	}
+	return matching
+}
+
+// applyDefaults adds client and host defaults into the http request, request Headers and QueryParams are set after it
+func (client *Client) applyDefaults(r *Request) {
+	headerLayers := []http.Header{client.headers}
+	queryLayers := []url.Values{client.queryParams}
+	for _, defaults := range client.matchingHostDefaults(r.HttpRequest.URL) {
+		headerLayers = append(headerLayers, defaults.headers)
+		queryLayers = append(queryLayers, defaults.queryParams)
+	}
+
+	for _, headers := range headerLayers {
+		for key, values := range headers {
+			if !r.removedHeaders[key] {
+				r.HttpRequest.Header[key] = append([]string(nil), values...)
+			}
+		}
+	}
+
+	// The URL already has the request query params and the ones embedded in Request.URL, defaults don't override them
+	query := r.HttpRequest.URL.Query()
+	own := r.HttpRequest.URL.Query()
+	changed := false
+	for _, params := range queryLayers {
+		for key, values := range params {
+			if _, ok := own[key]; ok || r.removedQueryParams[key] {
+				continue
+			}
+			query[key] = values
+			changed = true
+		}
+	}
+	for key := range r.removedQueryParams {
+		if _, ok := r.QueryParams[key]; ok {
+			continue
+		}
+		if _, ok := query[key]; ok {
+			query.Del(key)
+			changed = true
+		}
+	}
+	if changed {
+		r.HttpRequest.URL.RawQuery = query.Encode()
+	}
+}
+
+// defaultHeader returns the inherited value of header for r, before its URL is generated
+func (client *Client) defaultHeader(r *Request, header string) string {
+	key := http.CanonicalHeaderKey(header)
+	if r.removedHeaders[key] {
+		return ""
+	}
+	value := client.headers.Get(key)
+
+	rawURL := r.URL
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		for _, defaults := range client.matchingHostDefaults(u) {
+			if v := defaults.headers.Get(key); v != "" {
+				value = v
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,261 @@
+package tinyclient
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DigestAuth is an Authenticator for HTTP Digest authentication (RFC 7616)
+// The first request to a host is answered with a challenge, later requests to the host reuse the nonce with an increasing nonce count
+type DigestAuth struct {
+	Username string
+	Password string
+
+	mu sync.Mutex
+	//spaces holds the last challenge of every host, a host is the protection space of its challenge
+	spaces map[string]*digestSpace
+}
+
+// digestSpace is the current challenge of a host and the nonce count of its nonce
+type digestSpace struct {
+	challenge  *digestChallenge
+	nonceCount uint32
+}
+
+// digestChallenge holds the parameters of a WWW-Authenticate: Digest header
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	stale     bool
+}
+
+// NewDigestAuth creates a new DigestAuth authenticator
+func NewDigestAuth(username, password string) *DigestAuth {
+	return &DigestAuth{Username: username, Password: password}
+}
+
+// Authenticate writes the Authorization header when a challenge was already received, otherwise the request is sent as is
+func (auth *DigestAuth) Authenticate(httpRequest *http.Request) error {
+	auth.mu.Lock()
+	space := auth.spaces[requestHost(httpRequest)]
+	if space == nil {
+		auth.mu.Unlock()
+		return nil
+	}
+	challenge := space.challenge
+	space.nonceCount++
+	nonceCount := space.nonceCount
+	auth.mu.Unlock()
+
+	newHash := digestHash(challenge.algorithm)
+	if newHash == nil {
+		return fmt.Errorf("unsupported digest algorithm %q", challenge.algorithm)
+	}
+	h := func(s string) string {
+		hasher := newHash()
+		hasher.Write([]byte(s))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	cnonce, err := digestCnonce()
+	if err != nil {
+		return err
+	}
+	nc := fmt.Sprintf("%08x", nonceCount)
+	uri := httpRequest.URL.RequestURI()
+
+	ha1 := h(auth.Username + ":" + challenge.realm + ":" + auth.Password)
+	if strings.HasSuffix(strings.ToLower(challenge.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+
+	ha2 := h(httpRequest.Method + ":" + uri)
+	if challenge.qop == "auth-int" {
+		var body []byte
+		if httpRequest.GetBody != nil {
+			reader, err := httpRequest.GetBody()
+			if err != nil {
+				return err
+			}
+			if reader != nil {
+				if body, err = ioutil.ReadAll(reader); err != nil {
+					return err
+				}
+			}
+		}
+		ha2 = h(httpRequest.Method + ":" + uri + ":" + h(string(body)))
+	}
+
+	var response string
+	if challenge.qop == "" {
+		response = h(ha1 + ":" + challenge.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + challenge.nonce + ":" + nc + ":" + cnonce + ":" + challenge.qop + ":" + ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, response="%s"`,
+		auth.Username, challenge.realm, challenge.nonce, uri, challenge.algorithm, response)
+	if challenge.qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, challenge.qop, nc, cnonce)
+	}
+	if challenge.opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, challenge.opaque)
+	}
+	httpRequest.Header.Set("Authorization", header)
+	return nil
+}
+
+// Challenge stores the strongest supported Digest challenge of res for the host and resets its nonce count
+// It returns false when res has no usable challenge or the nonce sent with the request was rejected without being stale
+func (auth *DigestAuth) Challenge(res *http.Response) bool {
+	var best *digestChallenge
+	for _, header := range res.Header["Www-Authenticate"] {
+		challenge := parseDigestChallenge(header)
+		if challenge == nil || digestHash(challenge.algorithm) == nil {
+			continue
+		}
+		// SHA-256 is preferred over MD5 when the server offers both
+		if best == nil || strings.HasPrefix(strings.ToUpper(challenge.algorithm), "SHA-256") {
+			best = challenge
+		}
+	}
+	if best == nil {
+		return false
+	}
+
+	// Requests sent concurrently before the first challenge carry no nonce, they can all be retried
+	var host, sentNonce string
+	if res.Request != nil {
+		host = requestHost(res.Request)
+		sentNonce = digestNonce(res.Request.Header.Get("Authorization"))
+	}
+	if sentNonce != "" && sentNonce == best.nonce && !best.stale {
+		return false
+	}
+
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	if auth.spaces == nil {
+		auth.spaces = map[string]*digestSpace{}
+	}
+	// Another request may have stored this nonce already, its nonce count goes on
+	if space := auth.spaces[host]; space != nil && space.challenge.nonce == best.nonce {
+		return true
+	}
+	auth.spaces[host] = &digestSpace{challenge: best}
+	return true
+}
+
+// digestNonce returns the nonce of a Digest Authorization header value, or an empty string
+func digestNonce(authorization string) string {
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Digest ") {
+		return ""
+	}
+	return parseAuthParams(authorization[7:])["nonce"]
+}
+
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+func digestCnonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// parseDigestChallenge parses a WWW-Authenticate header value, it returns nil when it isn't a Digest challenge
+func parseDigestChallenge(header string) *digestChallenge {
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+		return nil
+	}
+	params := parseAuthParams(header[7:])
+
+	challenge := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+		stale:     strings.EqualFold(params["stale"], "true"),
+	}
+	if challenge.algorithm == "" {
+		challenge.algorithm = "MD5"
+	}
+	if challenge.nonce == "" {
+		return nil
+	}
+
+	// auth is preferred, auth-int is used when it is the only option
+	for _, qop := range strings.Split(params["qop"], ",") {
+		qop = strings.TrimSpace(qop)
+		if qop == "auth" {
+			challenge.qop = qop
+			break
+		}
+		if qop == "auth-int" {
+			challenge.qop = qop
+		}
+	}
+	return challenge
+}
+
+// parseAuthParams parses comma separated key=value and key="quoted, value" pairs
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end > len(s) {
+				end = len(s)
+			}
+			value = strings.Replace(s[1:end], `\"`, `"`, -1)
+			if end < len(s) {
+				end++
+			}
+			s = s[end:]
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	return params
+}
@@ -0,0 +1,195 @@
+package tinyclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hedgeSamples is the number of latencies kept for the percentile delay
+const hedgeSamples = 100
+
+// HedgePolicy sends another identical request when the previous one hasn't responded within the hedge delay
+// The first response wins and the other attempts are cancelled. Only GET and HEAD requests are hedged by default,
+// other methods including the idempotent PUT and DELETE are hedged only when a request opts in with SetHedging
+type HedgePolicy struct {
+	//Delay is the hedge delay until enough latencies are observed for Percentile
+	Delay time.Duration
+	//Percentile like 0.95 uses that percentile of the observed latencies as the hedge delay, 0 always uses Delay
+	Percentile float64
+	MinSamples int
+	//MaxAttempts is the number of attempts including the original request
+	MaxAttempts int
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+// NewHedgePolicy creates a new HedgePolicy sending at most one hedged request after delay
+func NewHedgePolicy(delay time.Duration) *HedgePolicy {
+	return &HedgePolicy{
+		Delay:       delay,
+		MinSamples:  20,
+		MaxAttempts: 2,
+	}
+}
+
+// SetPercentile makes the hedge delay follow the percentile of observed latencies, like 0.95
+func (policy *HedgePolicy) SetPercentile(percentile float64) *HedgePolicy {
+	policy.Percentile = percentile
+	return policy
+}
+
+// SetHedgePolicy sets the policy for hedging GET and HEAD requests and the requests which opt in with SetHedging
+func (client *Client) SetHedgePolicy(policy *HedgePolicy) *Client {
+	client.hedgePolicy = policy
+	return client
+}
+
+// SetHedging overrides whether the request is hedged, writes are sent more than once only when they opt in
+func (request *Request) SetHedging(enabled bool) *Request {
+	request.hedging = &enabled
+	return request
+}
+
+// hedgeable tells if request may be hedged, only GET and HEAD are by default
+// PUT and DELETE are idempotent too but they are hedged only when the request opts in, like every other write
+func hedgeable(request *Request) bool {
+	if request.hedging != nil {
+		return *request.hedging
+	}
+	switch request.Method {
+	case Get, Head:
+		return true
+	}
+	return false
+}
+
+// delay returns the current hedge delay
+func (policy *HedgePolicy) delay() time.Duration {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	if policy.Percentile <= 0 || len(policy.latencies) < policy.MinSamples || len(policy.latencies) == 0 {
+		return policy.Delay
+	}
+	sorted := make([]time.Duration, len(policy.latencies))
+	copy(sorted, policy.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(policy.Percentile * float64(len(sorted)))
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
+// observe keeps the latency of a winning attempt
+func (policy *HedgePolicy) observe(latency time.Duration) {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	if len(policy.latencies) < hedgeSamples {
+		policy.latencies = append(policy.latencies, latency)
+		return
+	}
+	policy.latencies[policy.next] = latency
+	policy.next = (policy.next + 1) % hedgeSamples
+}
+
+type hedgeResult struct {
+	attempt int
+	trace   *requestTrace
+	res     *http.Response
+	err     error
+	latency time.Duration
+}
+
+// hedge sends the request and its hedged attempts, the winning attempt is kept on request for its Response
+func (client *Client) hedge(request *Request) (*http.Response, error) {
+	policy := client.hedgePolicy
+	parent := request.HttpRequest.Context()
+	results := make(chan hedgeResult, policy.MaxAttempts)
+	cancels := make([]context.CancelFunc, 0, policy.MaxAttempts)
+
+	launch := func() {
+		ctx, cancel := context.WithCancel(parent)
+		cancels = append(cancels, cancel)
+		attempt := *request
+		attempt.HttpRequest = request.HttpRequest.Clone(ctx)
+		attempt.HttpRequest.Body = ioutil.NopCloser(bytes.NewReader(request.bodyBytes))
+		number := len(cancels)
+		go func() {
+			start := time.Now()
+			res, err := client.attempt(&attempt)
+			results <- hedgeResult{attempt: number, trace: attempt.trace, res: res, err: err, latency: time.Since(start)}
+		}()
+	}
+
+	delay := policy.delay()
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var firstErr error
+	for received := 0; received < len(cancels); {
+		select {
+		case <-timer.C:
+			if len(cancels) < policy.MaxAttempts {
+				fields := append(client.requestFields(request, nil), Field{Key: "attempt", Value: len(cancels) + 1}, Field{Key: "delay", Value: delay})
+				client.Logger.Log(LevelDebug, "hedging request", fields...)
+				launch()
+				timer.Reset(delay)
+			}
+		case result := <-results:
+			received++
+			if result.err != nil {
+				if firstErr == nil {
+					firstErr = result.err
+				}
+				continue
+			}
+			for i, cancel := range cancels {
+				if i+1 != result.attempt {
+					cancel()
+				}
+			}
+			go discardHedges(results, len(cancels)-received)
+
+			policy.observe(result.latency)
+			request.hedgeAttempt = result.attempt
+			request.trace = result.trace
+			result.res.Body = &cancelOnClose{ReadCloser: result.res.Body, cancel: cancels[result.attempt-1]}
+			return result.res, nil
+		}
+	}
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return nil, firstErr
+}
+
+// discardHedges closes the responses of the attempts which lost
+func discardHedges(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		if result := <-results; result.res != nil {
+			io.Copy(ioutil.Discard, result.res.Body)
+			result.res.Body.Close()
+		}
+	}
+}
+
+// cancelOnClose releases the context of the winning attempt when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
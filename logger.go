@@ -0,0 +1,105 @@
+package tinyclient
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+// Supported log levels
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (level Level) String() string {
+	switch level {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(level))
+}
+
+// Field is a key/value pair of a structured log entry
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger is a leveled structured logger, the client logs with fields like method, url, status, duration and attempt
+type Logger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+// NopLogger drops every log entry
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(level Level, msg string, fields ...Field) {}
+
+// StdLogger writes log entries at or above its level to a standard library logger as "LEVEL msg key=value ..."
+type StdLogger struct {
+	Logger *log.Logger
+	Level  Level
+}
+
+// NewStdLogger creates a new StdLogger writing entries at or above level
+func NewStdLogger(logger *log.Logger, level Level) *StdLogger {
+	return &StdLogger{Logger: logger, Level: level}
+}
+
+func (logger *StdLogger) Log(level Level, msg string, fields ...Field) {
+	if level < logger.Level {
+		return
+	}
+	var builder strings.Builder
+	builder.WriteString(level.String() + " " + msg)
+	for _, field := range fields {
+		value := fmt.Sprint(field.Value)
+		if strings.ContainsAny(value, " \"=\n") {
+			value = fmt.Sprintf("%q", value)
+		}
+		builder.WriteString(" " + field.Key + "=" + value)
+	}
+	logger.Logger.Output(2, builder.String())
+}
+
+// SetLogger sets the logger of the client, nil or NopLogger disables logging
+func (client *Client) SetLogger(logger Logger) *Client {
+	if logger == nil {
+		logger = NopLogger
+	}
+	client.Logger = logger
+	return client
+}
+
+// requestFields returns the fields describing request and its response, response may be nil
+func (client *Client) requestFields(request *Request, response *Response) []Field {
+	fields := []Field{{Key: "method", Value: request.Method}}
+	if request.HttpRequest != nil && request.HttpRequest.URL != nil {
+		fields = append(fields, Field{Key: "url", Value: client.redactor.URL(request.HttpRequest.URL)})
+	}
+	if response == nil {
+		return fields
+	}
+	if response.Response != nil {
+		fields = append(fields, Field{Key: "status", Value: response.Response.StatusCode})
+	}
+	fields = append(fields, Field{Key: "duration", Value: response.ReceivedAt.Sub(request.SentAt)})
+	if response.Attempt > 0 {
+		fields = append(fields, Field{Key: "attempt", Value: response.Attempt})
+	}
+	return fields
+}
@@ -0,0 +1,357 @@
+package tinyclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnexpectedRequest is returned by MockTransport for requests which match no expectation
+var ErrUnexpectedRequest = errors.New("mock transport has no matching expectation")
+
+// TestReporter is implemented by *testing.T and *testing.B
+type TestReporter interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatal(args ...interface{})
+}
+
+// MockTransport is an in-process http.RoundTripper answering requests from expectations
+// Unexpected requests fail with ErrUnexpectedRequest and are reported on t.Errorf as soon as they arrive
+// AssertExpectations reports the expectations which weren't called enough on t.Fatal
+type MockTransport struct {
+	t            TestReporter
+	mu           sync.Mutex
+	expectations []*Expectation
+	ordered      bool
+}
+
+// Expectation matches requests and tells how to respond, it expects to be called once by default
+type Expectation struct {
+	mock     *MockTransport
+	method   string
+	path     string
+	matchers []expectationMatcher
+	min      int
+	max      int
+	calls    int
+
+	status  int
+	headers http.Header
+	body    []byte
+	err     error
+}
+
+// expectationMatcher returns why the request doesn't match, or an empty string when it does
+type expectationMatcher func(httpRequest *http.Request, body []byte) string
+
+// NewMockTransport creates a new MockTransport reporting to t
+// Call defer mock.AssertExpectations() right after, expectations which are never called are only reported by it
+func NewMockTransport(t TestReporter) *MockTransport {
+	return &MockTransport{t: t}
+}
+
+// SetTransport sets the http.RoundTripper of the client, like a MockTransport
+func (client *Client) SetTransport(transport http.RoundTripper) *Client {
+	client.HTTPClient.Transport = transport
+	return client
+}
+
+// InOrder makes the expectations be called in the order they were added
+func (mock *MockTransport) InOrder() *MockTransport {
+	mock.ordered = true
+	return mock
+}
+
+// Expect adds an expectation for method and URL path, it responds 200 with an empty body unless told otherwise
+func (mock *MockTransport) Expect(method Method, path string) *Expectation {
+	expectation := &Expectation{
+		mock:    mock,
+		method:  string(method),
+		path:    path,
+		min:     1,
+		max:     1,
+		status:  http.StatusOK,
+		headers: http.Header{},
+	}
+	mock.mu.Lock()
+	mock.expectations = append(mock.expectations, expectation)
+	mock.mu.Unlock()
+	return expectation
+}
+
+// WithQuery matches requests having value among the values of the query parameter
+func (expectation *Expectation) WithQuery(key, value string) *Expectation {
+	expectation.matchers = append(expectation.matchers, func(httpRequest *http.Request, body []byte) string {
+		if !containsString(httpRequest.URL.Query()[key], value) {
+			return fmt.Sprintf("query %s=%q not found in %q", key, value, httpRequest.URL.RawQuery)
+		}
+		return ""
+	})
+	return expectation
+}
+
+// WithHeader matches requests having value among the values of the header
+func (expectation *Expectation) WithHeader(key, value string) *Expectation {
+	expectation.matchers = append(expectation.matchers, func(httpRequest *http.Request, body []byte) string {
+		values := httpRequest.Header[http.CanonicalHeaderKey(key)]
+		if !containsString(values, value) {
+			return fmt.Sprintf("header %s: %q not found in %q", key, value, values)
+		}
+		return ""
+	})
+	return expectation
+}
+
+// WithBody matches requests with exactly this body
+func (expectation *Expectation) WithBody(expected string) *Expectation {
+	expectation.matchers = append(expectation.matchers, func(httpRequest *http.Request, body []byte) string {
+		if string(body) != expected {
+			return fmt.Sprintf("body %q != %q", body, expected)
+		}
+		return ""
+	})
+	return expectation
+}
+
+// WithJSONBody matches requests whose JSON body equals v, key order and formatting don't matter
+func (expectation *Expectation) WithJSONBody(v interface{}) *Expectation {
+	expected, err := normalizeJSON(v)
+	expectation.matchers = append(expectation.matchers, func(httpRequest *http.Request, body []byte) string {
+		if err != nil {
+			return fmt.Sprintf("expected JSON body can't be marshalled: %v", err)
+		}
+		var actual interface{}
+		if json.Unmarshal(body, &actual) != nil {
+			return fmt.Sprintf("body %q is not JSON", body)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			expectedBytes, _ := json.Marshal(expected)
+			return fmt.Sprintf("JSON body %s != %s", body, expectedBytes)
+		}
+		return ""
+	})
+	return expectation
+}
+
+// Match adds a custom matcher, name describes it when the request doesn't match
+func (expectation *Expectation) Match(name string, matcher func(httpRequest *http.Request) bool) *Expectation {
+	expectation.matchers = append(expectation.matchers, func(httpRequest *http.Request, body []byte) string {
+		if !matcher(httpRequest) {
+			return name + " doesn't match"
+		}
+		return ""
+	})
+	return expectation
+}
+
+// Times expects exactly n calls
+func (expectation *Expectation) Times(n int) *Expectation {
+	expectation.min, expectation.max = n, n
+	return expectation
+}
+
+// Once expects exactly one call, it is the default
+func (expectation *Expectation) Once() *Expectation {
+	return expectation.Times(1)
+}
+
+// AnyTimes allows any number of calls, including none
+func (expectation *Expectation) AnyTimes() *Expectation {
+	expectation.min, expectation.max = 0, -1
+	return expectation
+}
+
+// Respond answers with status and body, body is sent as is when it is a string or []byte and as JSON otherwise
+func (expectation *Expectation) Respond(status int, body interface{}) *Expectation {
+	expectation.status = status
+	switch b := body.(type) {
+	case nil:
+		expectation.body = nil
+	case string:
+		expectation.body = []byte(b)
+	case []byte:
+		expectation.body = b
+	default:
+		expectation.body, expectation.err = json.Marshal(b)
+		expectation.headers.Set(ContentType, JsonContentType)
+	}
+	return expectation
+}
+
+// RespondHeader adds a response header
+func (expectation *Expectation) RespondHeader(key, value string) *Expectation {
+	expectation.headers.Add(key, value)
+	return expectation
+}
+
+// RespondError fails the request with err instead of responding
+func (expectation *Expectation) RespondError(err error) *Expectation {
+	expectation.err = err
+	return expectation
+}
+
+// Calls returns the number of requests answered by the expectation
+func (expectation *Expectation) Calls() int {
+	expectation.mock.mu.Lock()
+	defer expectation.mock.mu.Unlock()
+	return expectation.calls
+}
+
+func (expectation *Expectation) String() string {
+	return expectation.method + " " + expectation.path
+}
+
+// mismatch returns why the request doesn't match, or an empty string when it does
+func (expectation *Expectation) mismatch(httpRequest *http.Request, body []byte) string {
+	if httpRequest.Method != expectation.method {
+		return fmt.Sprintf("method %s != %s", httpRequest.Method, expectation.method)
+	}
+	if httpRequest.URL.Path != expectation.path {
+		return fmt.Sprintf("path %s != %s", httpRequest.URL.Path, expectation.path)
+	}
+	for _, matcher := range expectation.matchers {
+		if reason := matcher(httpRequest, body); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+func (expectation *Expectation) exhausted() bool {
+	return expectation.max >= 0 && expectation.calls >= expectation.max
+}
+
+func (mock *MockTransport) RoundTrip(httpRequest *http.Request) (*http.Response, error) {
+	var body []byte
+	if httpRequest.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(httpRequest.Body)
+		httpRequest.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	var reasons []string
+	for i, expectation := range mock.expectations {
+		reason := expectation.mismatch(httpRequest, body)
+		if reason == "" && expectation.exhausted() {
+			reason = fmt.Sprintf("already called %d times", expectation.calls)
+		}
+		if reason != "" {
+			reasons = append(reasons, fmt.Sprintf("  %s: %s", expectation, reason))
+			continue
+		}
+		if mock.ordered {
+			for _, previous := range mock.expectations[:i] {
+				if previous.calls < previous.min {
+					failure := fmt.Sprintf("request %s %s out of order, expected %s first", httpRequest.Method, httpRequest.URL, previous)
+					mock.t.Errorf("mock transport: %s", failure)
+					return nil, fmt.Errorf("%w: %s", ErrUnexpectedRequest, failure)
+				}
+			}
+		}
+		expectation.calls++
+		return expectation.response(httpRequest)
+	}
+
+	failure := describeRequest(httpRequest, body)
+	if len(reasons) == 0 {
+		failure += "\nno expectations"
+	} else {
+		failure += "\nexpectations:\n" + strings.Join(reasons, "\n")
+	}
+	mock.t.Errorf("mock transport: %s", failure)
+	return nil, fmt.Errorf("%w: %s %s", ErrUnexpectedRequest, httpRequest.Method, httpRequest.URL)
+}
+
+// AssertExpectations reports the expectations which weren't called enough on t.Fatal
+func (mock *MockTransport) AssertExpectations() {
+	mock.t.Helper()
+	mock.mu.Lock()
+	var failures []string
+	for _, expectation := range mock.expectations {
+		if expectation.calls < expectation.min {
+			failures = append(failures, fmt.Sprintf("expectation %s called %d times, expected %d", expectation, expectation.calls, expectation.min))
+		}
+	}
+	mock.mu.Unlock()
+
+	if len(failures) > 0 {
+		mock.t.Fatal("mock transport:\n" + strings.Join(failures, "\n"))
+	}
+}
+
+func (expectation *Expectation) response(httpRequest *http.Request) (*http.Response, error) {
+	if expectation.err != nil {
+		return nil, expectation.err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", expectation.status, http.StatusText(expectation.status)),
+		StatusCode:    expectation.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        expectation.headers.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(expectation.body)),
+		ContentLength: int64(len(expectation.body)),
+		Request:       httpRequest,
+	}, nil
+}
+
+// describeRequest writes the request with sorted headers for the failure report
+func describeRequest(httpRequest *http.Request, body []byte) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "unexpected request %s %s", httpRequest.Method, httpRequest.URL)
+	keys := make([]string, 0, len(httpRequest.Header))
+	for key := range httpRequest.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&builder, "\n  %s: %s", key, strings.Join(httpRequest.Header[key], ", "))
+	}
+	if len(body) > 0 {
+		fmt.Fprintf(&builder, "\n  body: %s", body)
+	}
+	return builder.String()
+}
+
+// normalizeJSON turns v into what json.Unmarshal gives for its JSON
+func normalizeJSON(v interface{}) (interface{}, error) {
+	var b []byte
+	switch value := v.(type) {
+	case string:
+		b = []byte(value)
+	case []byte:
+		b = value
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var normalized interface{}
+	err := json.Unmarshal(b, &normalized)
+	return normalized, err
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package tinyclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryDelta is how long before expires_in a cached token is considered expired
+const tokenExpiryDelta = 10 * time.Second
+
+// tokenErrorExcerpt is how many bytes of a failed token response are kept in the error
+const tokenErrorExcerpt = 200
+
+// ClientCredentials is an Authenticator which obtains OAuth2 access tokens with the client credentials grant (RFC 6749 4.4)
+// Tokens are cached until shortly before they expire and concurrent requests share one token request
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	//EndpointParams are extra form values sent to the token endpoint, like audience
+	EndpointParams url.Values
+	//Client sends the token requests, NewClientCredentials creates a default one
+	Client *Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// tokenResponse is the successful or error response of a token endpoint
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewClientCredentials creates a new ClientCredentials token source for the token endpoint
+func NewClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) *ClientCredentials {
+	return &ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		Client:       NewClient(),
+	}
+}
+
+func (source *ClientCredentials) Authenticate(httpRequest *http.Request) error {
+	token, err := source.Token()
+	if err != nil {
+		return err
+	}
+	httpRequest.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate drops the cached token if it is the one used by httpRequest, so the next Token call fetches a new one
+// A token which was already refreshed by another goroutine is kept
+func (source *ClientCredentials) Invalidate(httpRequest *http.Request) {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	if httpRequest.Header.Get("Authorization") == "Bearer "+source.token {
+		source.token = ""
+		source.expiry = time.Time{}
+	}
+}
+
+// Token returns the cached access token or fetches a new one when it is missing or about to expire
+func (source *ClientCredentials) Token() (string, error) {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+
+	if source.token != "" && (source.expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(source.expiry)) {
+		return source.token, nil
+	}
+
+	token, err := source.fetchToken()
+	if err != nil {
+		return "", err
+	}
+
+	source.token = token.AccessToken
+	source.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		source.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return source.token, nil
+}
+
+func (source *ClientCredentials) fetchToken() (*tokenResponse, error) {
+	client := source.Client
+	if client == nil {
+		client = NewClient()
+		source.Client = client
+	}
+
+	form := url.Values{}
+	for key, values := range source.EndpointParams {
+		form[key] = values
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(source.Scopes) > 0 {
+		form.Set("scope", strings.Join(source.Scopes, " "))
+	}
+
+	request := client.NewRequest().SetURL(source.TokenURL).SetMethod(Post).
+		SetContentType(formContentType).
+		AddHeader("Accept", "application/json").
+		SetBody(form.Encode()).
+		SetAuthenticator(NewBasicAuth(url.QueryEscape(source.ClientID), url.QueryEscape(source.ClientSecret)))
+
+	response, err := client.Send(request)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := response.ReadBody()
+	if err != nil {
+		return nil, err
+	}
+
+	// Error responses may not be JSON, like an HTML 502 of a proxy, so the status is checked first
+	token := &tokenResponse{}
+	if response.Response.StatusCode != http.StatusOK {
+		if json.Unmarshal(body, token) == nil && token.Error != "" {
+			err := fmt.Errorf("token endpoint returned %s: %s %s", response.Response.Status, token.Error, token.ErrorDescription)
+			return nil, err
+		}
+		err := fmt.Errorf("token endpoint returned %s: %s", response.Response.Status, bodyExcerpt(body, tokenErrorExcerpt))
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("can't parse token endpoint response: %w", err)
+	}
+	if token.Error != "" {
+		err := fmt.Errorf("token endpoint returned %s: %s %s", response.Response.Status, token.Error, token.ErrorDescription)
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		err := errors.New("token endpoint returned an empty access_token")
+		return nil, err
+	}
+	return token, nil
+}
+
+// bodyExcerpt returns at most max bytes of body for error messages
+func bodyExcerpt(body []byte, max int) string {
+	excerpt := strings.TrimSpace(string(body))
+	if len(excerpt) > max {
+		return excerpt[:max] + "..."
+	}
+	return excerpt
+}
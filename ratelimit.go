@@ -0,0 +1,169 @@
+package tinyclient
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by Send when the wait for the rate limiter would pass the deadline of the request context
+var ErrRateLimited = errors.New("rate limit wait exceeds the context deadline")
+
+// RateLimiter limits the request rate with one token bucket per key, the host by default.
+// Requests wait for a token within the deadline of their context.
+// In adaptive mode a 429 Too Many Requests response halves the rate of its bucket down to MinRate
+// and honors Retry-After, every other response raises the rate back towards Rate
+type RateLimiter struct {
+	//Rate is the number of requests per second, 0 doesn't limit the rate and only Retry-After is honored
+	Rate  float64
+	Burst int
+	//KeyFunc selects the bucket of a request, default is KeyByHost
+	KeyFunc  func(request *Request) string
+	Adaptive bool
+	MinRate  float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+	//blockedUntil is set from Retry-After of a 429 response
+	blockedUntil time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter keyed by host, rate is requests per second and 0 or less is unlimited
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		Rate:    rate,
+		Burst:   burst,
+		MinRate: rate / 10,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// SetRateLimiter sets the rate limiter waited on before every request sent over the network
+func (client *Client) SetRateLimiter(limiter *RateLimiter) *Client {
+	client.rateLimiter = limiter
+	return client
+}
+
+// CurrentRate returns the rate of the bucket with key, it differs from Rate only in adaptive mode
+func (limiter *RateLimiter) CurrentRate(key string) float64 {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if bucket, ok := limiter.buckets[key]; ok {
+		return bucket.rate
+	}
+	return limiter.Rate
+}
+
+// wait takes a token from the bucket of request, it sleeps until the token is available
+func (limiter *RateLimiter) wait(request *Request) error {
+	key := limiter.key(request)
+	ctx := request.HttpRequest.Context()
+	now := time.Now()
+
+	limiter.mu.Lock()
+	bucket := limiter.bucket(key, now)
+	bucket.refill(now, float64(limiter.Burst))
+	unlimited := limiter.Rate <= 0
+	delay := time.Duration(0)
+	if !unlimited && bucket.tokens < 1 {
+		// A rate lowered towards 0 in adaptive mode gives a wait longer than a time.Duration can hold
+		delay = time.Duration(math.MaxInt64)
+		if wait := (1 - bucket.tokens) / bucket.rate * float64(time.Second); wait < float64(math.MaxInt64) {
+			delay = time.Duration(wait)
+		}
+	}
+	if blocked := bucket.blockedUntil.Sub(now); blocked > delay {
+		delay = blocked
+	}
+	if deadline, ok := ctx.Deadline(); ok && delay > deadline.Sub(now) {
+		limiter.mu.Unlock()
+		return fmt.Errorf("%w: %s needs to wait %v", ErrRateLimited, key, delay)
+	}
+	// The token is reserved now, so concurrent requests queue up behind it
+	if !unlimited {
+		bucket.tokens--
+	}
+	limiter.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		if !unlimited {
+			limiter.mu.Lock()
+			bucket.tokens++
+			limiter.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// observe adapts the rate of the bucket of request to its response
+func (limiter *RateLimiter) observe(request *Request, res *http.Response) {
+	if !limiter.Adaptive || res == nil {
+		return
+	}
+	key := limiter.key(request)
+	now := time.Now()
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	bucket := limiter.bucket(key, now)
+	bucket.refill(now, float64(limiter.Burst))
+
+	if res.StatusCode != http.StatusTooManyRequests {
+		bucket.rate = math.Min(limiter.Rate, bucket.rate+limiter.Rate/20)
+		return
+	}
+	bucket.rate = math.Max(limiter.MinRate, bucket.rate/2)
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		bucket.blockedUntil = now.Add(time.Duration(seconds) * time.Second)
+	} else if at, err := http.ParseTime(res.Header.Get("Retry-After")); err == nil {
+		bucket.blockedUntil = at
+	}
+}
+
+// bucket returns the bucket of key, the lock must be held
+func (limiter *RateLimiter) bucket(key string, now time.Time) *tokenBucket {
+	if limiter.buckets == nil {
+		limiter.buckets = map[string]*tokenBucket{}
+	}
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{rate: limiter.Rate, tokens: float64(limiter.Burst), last: now}
+		limiter.buckets[key] = bucket
+	}
+	return bucket
+}
+
+func (limiter *RateLimiter) key(request *Request) string {
+	if limiter.KeyFunc != nil {
+		return limiter.KeyFunc(request)
+	}
+	return KeyByHost(request)
+}
+
+func (bucket *tokenBucket) refill(now time.Time, burst float64) {
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = math.Min(burst, bucket.tokens+elapsed.Seconds()*bucket.rate)
+		bucket.last = now
+	}
+}
@@ -0,0 +1,244 @@
+package tinyclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces the values hidden by a Redactor
+const RedactedValue = "[REDACTED]"
+
+// defaultRedactedHeaders carry credentials and are always worth hiding
+var defaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Amz-Security-Token",
+}
+
+// defaultRedactedQueryParams are common names of credentials sent in the query
+var defaultRedactedQueryParams = []string{
+	"access_token",
+	"api_key",
+	"apikey",
+	"client_secret",
+	"password",
+	"token",
+}
+
+// Redactor hides sensitive data in the debug output and in the url field of log entries
+type Redactor struct {
+	//Headers are matched case insensitively
+	Headers map[string]bool
+	//QueryParams are matched case sensitively like url.Values
+	QueryParams map[string]bool
+	//JSONFields are dot separated paths like "data.attributes.iban", * matches any key and arrays are walked through
+	JSONFields [][]string
+	//MaxBodyLength truncates logged bodies, 0 logs whole bodies
+	MaxBodyLength int
+}
+
+// NewRedactor creates a new Redactor hiding credential headers and query parameters
+func NewRedactor() *Redactor {
+	redactor := &Redactor{Headers: map[string]bool{}, QueryParams: map[string]bool{}}
+	redactor.AddHeaders(defaultRedactedHeaders...)
+	redactor.AddQueryParams(defaultRedactedQueryParams...)
+	return redactor
+}
+
+// SetRedactor sets the redaction policy of the debug output, nil logs everything as is
+func (client *Client) SetRedactor(redactor *Redactor) *Client {
+	client.redactor = redactor
+	return client
+}
+
+// AddHeaders adds headers to the deny-list
+func (redactor *Redactor) AddHeaders(headers ...string) *Redactor {
+	if redactor.Headers == nil {
+		redactor.Headers = map[string]bool{}
+	}
+	for _, header := range headers {
+		redactor.Headers[http.CanonicalHeaderKey(header)] = true
+	}
+	return redactor
+}
+
+// AddQueryParams adds query parameters to the deny-list
+func (redactor *Redactor) AddQueryParams(params ...string) *Redactor {
+	if redactor.QueryParams == nil {
+		redactor.QueryParams = map[string]bool{}
+	}
+	for _, param := range params {
+		redactor.QueryParams[param] = true
+	}
+	return redactor
+}
+
+// AddJSONFields masks the values at the JSON paths, like "data.attributes.name" or "data.*.iban"
+func (redactor *Redactor) AddJSONFields(paths ...string) *Redactor {
+	for _, path := range paths {
+		redactor.JSONFields = append(redactor.JSONFields, strings.Split(path, "."))
+	}
+	return redactor
+}
+
+// SetMaxBodyLength truncates logged bodies longer than length bytes
+func (redactor *Redactor) SetMaxBodyLength(length int) *Redactor {
+	redactor.MaxBodyLength = length
+	return redactor
+}
+
+// Header returns a copy of header with the values of denied headers masked
+func (redactor *Redactor) Header(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for key, values := range header {
+		if redactor != nil && redactor.Headers[http.CanonicalHeaderKey(key)] {
+			values = []string{RedactedValue}
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+// URL returns u as a string with the values of denied query parameters masked
+func (redactor *Redactor) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if redactor == nil || u.RawQuery == "" {
+		return u.String()
+	}
+	query := u.Query()
+	changed := false
+	for key := range query {
+		if redactor.QueryParams[key] {
+			query[key] = []string{RedactedValue}
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	masked := *u
+	masked.RawQuery = strings.Replace(query.Encode(), url.QueryEscape(RedactedValue), RedactedValue, -1)
+	return masked.String()
+}
+
+// errorURLPattern finds the URLs in an error message
+var errorURLPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// Error returns err with the query parameters masked in every URL of its message, the result unwraps to err
+func (redactor *Redactor) Error(err error) error {
+	if redactor == nil || err == nil {
+		return err
+	}
+	message := err.Error()
+	masked := errorURLPattern.ReplaceAllStringFunc(message, func(raw string) string {
+		u, parseErr := url.Parse(raw)
+		if parseErr != nil {
+			return raw
+		}
+		if redacted := redactor.URL(u); redacted != u.String() {
+			return redacted
+		}
+		return raw
+	})
+	if masked == message {
+		return err
+	}
+	return &redactedError{err: err, message: masked}
+}
+
+// redactedError is an error whose message is redacted, errors.Is and errors.As still see the original error
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (redacted *redactedError) Error() string {
+	return redacted.message
+}
+
+func (redacted *redactedError) Unwrap() error {
+	return redacted.err
+}
+
+// Body returns body with the JSON fields masked and truncated to MaxBodyLength
+func (redactor *Redactor) Body(body []byte) string {
+	masked, truncated := redactor.body(body)
+	if truncated > 0 {
+		return fmt.Sprintf("%s... (%d bytes truncated)", masked, truncated)
+	}
+	return string(masked)
+}
+
+// body masks the JSON fields of body and truncates it, it returns the number of truncated bytes
+func (redactor *Redactor) body(body []byte) ([]byte, int) {
+	if redactor == nil {
+		return body, 0
+	}
+	body, _ = redactor.mask(body)
+	if redactor.MaxBodyLength > 0 && len(body) > redactor.MaxBodyLength {
+		return body[:redactor.MaxBodyLength], len(body) - redactor.MaxBodyLength
+	}
+	return body, 0
+}
+
+// mask masks the JSON fields of body, it returns true when something was masked
+func (redactor *Redactor) mask(body []byte) ([]byte, bool) {
+	if redactor == nil || len(redactor.JSONFields) == 0 || len(body) == 0 {
+		return body, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document interface{}
+	if decoder.Decode(&document) != nil {
+		return body, false
+	}
+	masked := false
+	for _, path := range redactor.JSONFields {
+		masked = maskJSON(document, path) || masked
+	}
+	if !masked {
+		return body, false
+	}
+	b, err := json.Marshal(document)
+	if err != nil {
+		return body, false
+	}
+	return b, true
+}
+
+// maskJSON replaces the values at path in the decoded document, it returns true when something was masked
+func maskJSON(document interface{}, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	masked := false
+	switch node := document.(type) {
+	case []interface{}:
+		for _, item := range node {
+			masked = maskJSON(item, path) || masked
+		}
+	case map[string]interface{}:
+		for key, value := range node {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				node[key] = RedactedValue
+				masked = true
+			} else {
+				masked = maskJSON(value, path[1:]) || masked
+			}
+		}
+	}
+	return masked
+}
@@ -0,0 +1,308 @@
+package tinyclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// replaySkippedHeaders are set by the client or the transport when the request is replayed
+var replaySkippedHeaders = map[string]bool{
+	"Content-Length": true,
+	"Host":           true,
+	"User-Agent":     true,
+}
+
+// Replayer sends recorded AuditRecord lines again through Client against BaseURL and compares the responses
+// Redacted headers and query parameters aren't replayed, credentials come from the client authenticator
+// Records with a truncated or masked request body fail, their body can't be sent again
+type Replayer struct {
+	Client  *Client
+	BaseURL string
+	//Rate is the number of requests per second, 0 replays as fast as possible
+	Rate float64
+	//Variables replace ${name} in URLs, header values and bodies
+	Variables map[string]string
+	//IgnoredFields are JSON paths like "data.id" which are not compared
+	IgnoredFields [][]string
+	//OnResult is called after every replayed record
+	OnResult func(result ReplayResult)
+}
+
+// ReplayResult compares one recorded exchange to its replay
+type ReplayResult struct {
+	//Line is the line number of the record in the replayed file
+	Line        int
+	Record      AuditRecord
+	Status      int
+	Body        string
+	Duration    time.Duration
+	StatusMatch bool
+	BodyMatch   bool
+	Err         error
+}
+
+// Matched tells if the replay returned the recorded status and body
+func (result ReplayResult) Matched() bool {
+	return result.Err == nil && result.StatusMatch && result.BodyMatch
+}
+
+// ReplayReport is the comparison of every replayed record
+type ReplayReport struct {
+	Results    []ReplayResult
+	Matched    int
+	Mismatched int
+	Failed     int
+}
+
+// NewReplayer creates a new Replayer sending the records with client to baseURL, like "http://localhost:8080"
+func NewReplayer(client *Client, baseURL string) *Replayer {
+	return &Replayer{Client: client, BaseURL: baseURL, Variables: map[string]string{}}
+}
+
+// SetRate limits the replay to rate requests per second
+func (replayer *Replayer) SetRate(rate float64) *Replayer {
+	replayer.Rate = rate
+	return replayer
+}
+
+// SetVariable replaces ${name} with value in the replayed requests
+func (replayer *Replayer) SetVariable(name, value string) *Replayer {
+	if replayer.Variables == nil {
+		replayer.Variables = map[string]string{}
+	}
+	replayer.Variables[name] = value
+	return replayer
+}
+
+// IgnoreFields skips the JSON paths when the bodies are compared, like "data.id" or "data.*.created_on"
+func (replayer *Replayer) IgnoreFields(paths ...string) *Replayer {
+	for _, path := range paths {
+		replayer.IgnoredFields = append(replayer.IgnoredFields, strings.Split(path, "."))
+	}
+	return replayer
+}
+
+// ReplayFile replays the records of a JSON Lines file in order
+func (replayer *Replayer) ReplayFile(ctx context.Context, filename string) (*ReplayReport, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return replayer.Replay(ctx, file)
+}
+
+// Replay replays the records read from reader in order, it stops when ctx is done or a line isn't a record
+func (replayer *Replayer) Replay(ctx context.Context, reader io.Reader) (*ReplayReport, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var interval time.Duration
+	if replayer.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / replayer.Rate)
+	}
+
+	report := &ReplayReport{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var next time.Time
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return report, fmt.Errorf("line %d is not a record: %w", line, err)
+		}
+
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return report, ctx.Err()
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		next = time.Now().Add(interval)
+
+		result := replayer.replay(ctx, line, record)
+		switch {
+		case result.Err != nil:
+			report.Failed++
+		case result.Matched():
+			report.Matched++
+		default:
+			report.Mismatched++
+		}
+		report.Results = append(report.Results, result)
+		if replayer.OnResult != nil {
+			replayer.OnResult(result)
+		}
+	}
+	return report, scanner.Err()
+}
+
+func (replayer *Replayer) replay(ctx context.Context, line int, record AuditRecord) ReplayResult {
+	result := ReplayResult{Line: line, Record: record}
+	client := replayer.Client
+
+	request, err := replayer.request(record)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	request.ctx = ctx
+
+	start := time.Now()
+	response, err := client.Send(request)
+	result.Duration = time.Since(start)
+	if err != nil && response == nil {
+		result.Err = err
+		return result
+	}
+	body, err := response.ReadBody()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	// The new body gets the same redaction and excerpt as the recorded one so they can be compared
+	redactor, limit := client.redactor, DefaultAuditBodyLength
+	if client.auditSink != nil {
+		limit = client.auditSink.MaxBodyLength
+		if client.auditSink.Redactor != nil {
+			redactor = client.auditSink.Redactor
+		}
+	}
+	complete := limit <= 0 || len(body) <= limit
+	if !complete {
+		body = body[:limit+1]
+	}
+	result.Status = response.Response.StatusCode
+	result.Body = auditBody(redactor, body, complete, limit).body
+	result.StatusMatch = result.Status == record.Status
+	result.BodyMatch = replayer.equalBodies(record.ResponseBody, result.Body)
+	return result
+}
+
+// request creates the request of record against BaseURL with the variables replaced
+func (replayer *Replayer) request(record AuditRecord) (*Request, error) {
+	pairs := make([]string, 0, len(replayer.Variables)*2)
+	for name, value := range replayer.Variables {
+		pairs = append(pairs, "${"+name+"}", value)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	recorded, err := url.Parse(replacer.Replace(record.URL))
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(replayer.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	target := *recorded
+	target.Scheme, target.Host = base.Scheme, base.Host
+	target.Path = strings.TrimSuffix(base.Path, "/") + recorded.Path
+	target.RawPath = ""
+	if query := target.Query(); len(query) > 0 {
+		dropped := false
+		for key, values := range query {
+			kept := values[:0]
+			for _, value := range values {
+				if value != RedactedValue {
+					kept = append(kept, value)
+				}
+			}
+			if len(kept) < len(values) {
+				dropped = true
+				if len(kept) == 0 {
+					delete(query, key)
+				} else {
+					query[key] = kept
+				}
+			}
+		}
+		if dropped {
+			target.RawQuery = query.Encode()
+		}
+	}
+
+	if record.RequestBodyTruncated {
+		return nil, errors.New("recorded request body is truncated")
+	}
+	if record.RequestBodyRedacted || strings.Contains(record.RequestBody, RedactedValue) {
+		return nil, errors.New("recorded request body is redacted")
+	}
+	body := []byte(record.RequestBody)
+	if record.RequestBodyEncoding == "base64" {
+		if body, err = base64.StdEncoding.DecodeString(record.RequestBody); err != nil {
+			return nil, err
+		}
+	} else {
+		body = []byte(replacer.Replace(record.RequestBody))
+	}
+
+	request := replayer.Client.NewRequest().SetURL(target.String()).SetMethod(Method(record.Method))
+	if len(body) > 0 {
+		request.SetBody(body)
+	}
+	for key, values := range record.RequestHeaders {
+		if replaySkippedHeaders[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		for _, value := range values {
+			if value != RedactedValue {
+				request.AddHeader(key, replacer.Replace(value))
+			}
+		}
+	}
+	return request, nil
+}
+
+// equalBodies compares JSON bodies without the ignored fields, other bodies byte by byte
+func (replayer *Replayer) equalBodies(recorded, replayed string) bool {
+	var recordedJSON, replayedJSON interface{}
+	if json.Unmarshal([]byte(recorded), &recordedJSON) != nil || json.Unmarshal([]byte(replayed), &replayedJSON) != nil {
+		return recorded == replayed
+	}
+	for _, path := range replayer.IgnoredFields {
+		maskJSON(recordedJSON, path)
+		maskJSON(replayedJSON, path)
+	}
+	return reflect.DeepEqual(recordedJSON, replayedJSON)
+}
+
+// String summarizes the report with a line for every record which didn't match
+func (report *ReplayReport) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d replayed, %d matched, %d mismatched, %d failed\n",
+		len(report.Results), report.Matched, report.Mismatched, report.Failed)
+	for _, result := range report.Results {
+		switch {
+		case result.Err != nil:
+			fmt.Fprintf(&builder, "line %d %s %s: %v\n", result.Line, result.Record.Method, result.Record.URL, result.Err)
+		case !result.StatusMatch:
+			fmt.Fprintf(&builder, "line %d %s %s: status %d, recorded %d\n", result.Line, result.Record.Method, result.Record.URL, result.Status, result.Record.Status)
+		case !result.BodyMatch:
+			fmt.Fprintf(&builder, "line %d %s %s: body differs\n", result.Line, result.Record.Method, result.Record.URL)
+		}
+	}
+	return builder.String()
+}
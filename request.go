@@ -1,6 +1,7 @@
 package tinyclient
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -18,6 +19,7 @@ type Method string
 // Supported HTTP methods, for preventing typos
 const (
 	Get    Method = "GET"
+	Head   Method = "HEAD"
 	Post   Method = "POST"
 	Put    Method = "PUT"
 	Patch  Method = "PATCH"
@@ -34,11 +36,29 @@ type Request struct {
 	//bodyBytes is not exposed, it is for holding Body interface internally as bytes and reading the body without spoiling HttpRequest.GetBody
 	bodyBytes   []byte
 	Cookies     []*http.Cookie
-	Headers     map[string]string
+	Headers     http.Header
 	QueryParams map[string]string
 	FormData    url.Values
 	SentAt      time.Time
 	useSSL      bool
+	//authenticator overrides the client authenticator when it is not nil
+	authenticator Authenticator
+	//removedHeaders and removedQueryParams are client or host defaults which are not sent with this request
+	removedHeaders     map[string]bool
+	removedQueryParams map[string]bool
+	//version is sent with versionStrategy, precondition makes Send return 409 and 412 responses as errors
+	version         interface{}
+	versionStrategy VersionStrategy
+	precondition    bool
+	//hedgeAttempt is the attempt which won when the request was hedged, hedging overrides hedging by method
+	hedgeAttempt int
+	hedging      *bool
+	//ctx is set by SendAll and replaces the client context
+	ctx context.Context
+	//trace collects the timings of the request sent over the network
+	trace *requestTrace
+	//route is the route template used to group requests of the same endpoint
+	route string
 }
 
 func (request *Request) SetBody(body interface{}) *Request {
@@ -56,22 +76,52 @@ func (request *Request) SetURL(url string) *Request {
 	return request
 }
 
+// AddHeader appends a value to the request header, existing values are kept
 func (request *Request) AddHeader(header, value string) *Request {
-	request.Headers[header] = value
+	request.Headers.Add(header, value)
 	return request
 }
 
-// AddHeaders sets request headers
+// SetHeader replaces all values of the request header
+func (request *Request) SetHeader(header, value string) *Request {
+	request.Headers.Set(header, value)
+	return request
+}
+
+// AddHeaders appends request headers, existing values are kept
 func (request *Request) AddHeaders(headers map[string]string) *Request {
 	for k, v := range headers {
-		request.Headers[k] = v
+		request.Headers.Add(k, v)
+	}
+	return request
+}
+
+// SetHeaders replaces request headers
+func (request *Request) SetHeaders(headers map[string]string) *Request {
+	for k, v := range headers {
+		request.Headers.Set(k, v)
+	}
+	return request
+}
+
+// RemoveHeader removes a header of the request, including a default inherited from the client
+func (request *Request) RemoveHeader(header string) *Request {
+	key := http.CanonicalHeaderKey(header)
+	for k := range request.Headers {
+		if http.CanonicalHeaderKey(k) == key {
+			delete(request.Headers, k)
+		}
+	}
+	if request.removedHeaders == nil {
+		request.removedHeaders = map[string]bool{}
 	}
+	request.removedHeaders[key] = true
 	return request
 }
 
 // SetContentType sets content type of request
 func (request *Request) SetContentType(contentType string) *Request {
-	request.Headers[ContentType] = contentType
+	request.Headers.Set(ContentType, contentType)
 	return request
 }
 
@@ -83,7 +133,6 @@ func (request *Request) ReadBody() ([]byte, error) {
 	}
 
 	err := errors.New("bodyBytes is empty")
-	request.client.ErrorLogger.Println(err)
 	return nil, err
 }
 
@@ -99,9 +148,41 @@ func (request *Request) AddQueryParams(params map[string]string) *Request {
 	return request
 }
 
+// RemoveQueryParam removes a query param of the request, including a default inherited from the client
+func (request *Request) RemoveQueryParam(param string) *Request {
+	delete(request.QueryParams, param)
+	if request.removedQueryParams == nil {
+		request.removedQueryParams = map[string]bool{}
+	}
+	request.removedQueryParams[param] = true
+	return request
+}
+
+// SetRoute sets the route template of the request like "/v1/organisation/accounts/{id}", it groups requests of the same endpoint
+func (request *Request) SetRoute(route string) *Request {
+	request.route = route
+	return request
+}
+
+// KeyByHost groups requests by the host of the request URL
+func KeyByHost(request *Request) string {
+	return request.HttpRequest.URL.Host
+}
+
+// KeyByRoute groups requests by host and the route template of the request, or by host when it has no route
+func KeyByRoute(request *Request) string {
+	if request.route == "" {
+		return request.HttpRequest.URL.Host
+	}
+	return request.HttpRequest.URL.Host + " " + request.route
+}
+
 //parseRequestBody logics can't be in Request because of checking contentType
 func (request *Request) parseRequestBody() (err error) {
-	contentType := request.Headers[ContentType]
+	contentType := request.Headers.Get(ContentType)
+	if contentType == "" && request.client != nil {
+		contentType = request.client.defaultHeader(request, ContentType)
+	}
 	if request.Body == nil {
 		return
 	}
@@ -113,7 +194,6 @@ func (request *Request) parseRequestBody() (err error) {
 	if reader, ok := request.Body.(io.Reader); ok {
 		request.bodyBytes, err = ioutil.ReadAll(reader)
 		if err != nil {
-			request.client.ErrorLogger.Println(err)
 			return err
 		}
 	} else if b, ok := request.Body.([]byte); ok {
@@ -124,13 +204,11 @@ func (request *Request) parseRequestBody() (err error) {
 		if kind == reflect.Struct || kind == reflect.Map || kind == reflect.Slice {
 			b, err := json.Marshal(request.Body)
 			if err != nil {
-				request.client.ErrorLogger.Println(err)
 				return err
 			}
 			request.bodyBytes = b
 		} else if kind == reflect.Ptr {
 			err := errors.New("Request body is pointer which we don't support for now, please use the value at the pointer as body")
-			request.client.ErrorLogger.Println(err)
 			return err
 		}
 	}
@@ -166,7 +244,6 @@ func (request *Request) generateURL() (*url.URL, error) {
 	// Parse URL
 	parsedURL, err := url.Parse(URL)
 	if err != nil {
-		request.client.ErrorLogger.Println(err)
 		return nil, err
 	}
 
@@ -15,6 +15,12 @@ type Response struct {
 	Response   *http.Response
 	bodyBytes  []byte
 	ReceivedAt time.Time
+	//FromCache is true when the response was served or revalidated from the client cache
+	FromCache bool
+	//Attempt is the hedged attempt which won, 1 is the original request and 0 means the request wasn't hedged
+	Attempt int
+	//Coalesced is true when the response was shared from an identical request in flight
+	Coalesced bool
 }
 
 // ReadBody reads the http.Response bodyBytes and assigns it to the r.Body
@@ -28,35 +34,31 @@ func (response *Response) ReadBody() ([]byte, error) {
 	// Check if Response.resp (*http.Response) is nil
 	if response.Response == nil {
 		err := fmt.Errorf("http.Response is nil")
-		response.client.ErrorLogger.Println(err)
 		return nil, err
 	}
 
 	// Check if Response.resp.Body (*http.Response.Body) is nil
 	if response.Response.Body == nil {
 		err := fmt.Errorf("http.Response's Body is nil")
-		response.client.ErrorLogger.Println(err)
 		return nil, err
 	}
 
 	// Read response bodyBytes
 	b, err := ioutil.ReadAll(response.Response.Body)
 	if err != nil {
-		response.client.ErrorLogger.Printf("Can't read http.Response bodyBytes Error: %v!", err)
-		return nil, err
+		return nil, fmt.Errorf("can't read http.Response body: %w", err)
 	}
 
 	// Set response readBody
 	response.bodyBytes = b
 	if len(response.bodyBytes) == 0 {
-		response.client.InfoLogger.Println("Response body is empty")
+		response.client.Logger.Log(LevelDebug, "response body is empty")
 	}
 
 	// Close response bodyBytes
 	err = response.Response.Body.Close()
 	if err != nil {
-		response.client.ErrorLogger.Printf("Can't close http.Response body Error: %v!", err)
-		return nil, err
+		return nil, fmt.Errorf("can't close http.Response body: %w", err)
 	}
 
 	return b, nil
@@ -73,7 +75,6 @@ func (response *Response) BodyUnmarshall(v interface{}) error {
 	//if len(resBody) == 0 can be handled later
 	err = json.Unmarshal(resBody, v)
 	if err != nil {
-		response.client.ErrorLogger.Println(err)
 		return err
 	}
 	return nil
@@ -0,0 +1,447 @@
+package tinyclient
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer signs the final *http.Request, body is the buffered request body
+type Signer interface {
+	Sign(httpRequest *http.Request, body []byte) error
+}
+
+// SignatureScheme selects the header format of HTTPSigner
+type SignatureScheme string
+
+// Supported signature schemes
+const (
+	// SignatureCavage writes Digest and Signature headers as in draft-cavage-http-signatures-12
+	SignatureCavage SignatureScheme = "cavage"
+	// SignatureRFC9421 writes Content-Digest, Signature-Input and Signature headers as in RFC 9421
+	SignatureRFC9421 SignatureScheme = "rfc9421"
+)
+
+var (
+	cavageComponents  = []string{"(request-target)", "host", "date", "digest"}
+	rfc9421Components = []string{"@method", "@authority", "@path", "@query", "content-digest"}
+)
+
+// HTTPSigner signs requests with an RSA, ECDSA or Ed25519 private key
+type HTTPSigner struct {
+	KeyID  string
+	Key    crypto.Signer
+	Scheme SignatureScheme
+	//Components are the signed headers and derived components, defaults depend on Scheme
+	Components []string
+	//Label is the RFC 9421 signature label, default is sig1
+	Label string
+}
+
+// NewHTTPSigner creates a new HTTPSigner, key must be *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey
+func NewHTTPSigner(keyID string, key crypto.Signer, scheme SignatureScheme) *HTTPSigner {
+	return &HTTPSigner{KeyID: keyID, Key: key, Scheme: scheme}
+}
+
+// SetComponents sets the headers and derived components covered by the signature
+func (signer *HTTPSigner) SetComponents(components ...string) *HTTPSigner {
+	signer.Components = components
+	return signer
+}
+
+// SetSigner sets the Signer applied to every request after its Authenticator
+func (client *Client) SetSigner(signer Signer) *Client {
+	client.signer = signer
+	return client
+}
+
+func (signer *HTTPSigner) Sign(httpRequest *http.Request, body []byte) error {
+	if signer.Key == nil {
+		return errors.New("http signer key is nil")
+	}
+	components := signer.components()
+
+	for _, component := range components {
+		switch component {
+		case "digest":
+			sum := sha256.Sum256(body)
+			httpRequest.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		case "content-digest":
+			sum := sha256.Sum256(body)
+			httpRequest.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+		case "date":
+			if httpRequest.Header.Get("Date") == "" {
+				httpRequest.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+			}
+		}
+	}
+
+	created := time.Now().Unix()
+	algorithm, err := signatureAlgorithm(signer.Key.Public(), signer.Scheme)
+	if err != nil {
+		return err
+	}
+
+	if signer.Scheme == SignatureRFC9421 {
+		params := signatureParams(components, created, signer.KeyID, algorithm)
+		base, err := rfc9421Base(httpRequest, components, params)
+		if err != nil {
+			return err
+		}
+		signature, err := signMessage(signer.Key, signer.Scheme, []byte(base))
+		if err != nil {
+			return err
+		}
+		label := signer.label()
+		httpRequest.Header.Set("Signature-Input", label+"="+params)
+		httpRequest.Header.Set("Signature", label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+		return nil
+	}
+
+	base, err := cavageBase(httpRequest, components, created)
+	if err != nil {
+		return err
+	}
+	signature, err := signMessage(signer.Key, signer.Scheme, []byte(base))
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf(`keyId="%s",algorithm="%s",`, signer.KeyID, algorithm)
+	for _, component := range components {
+		if component == "(created)" {
+			header += fmt.Sprintf("created=%d,", created)
+			break
+		}
+	}
+	header += fmt.Sprintf(`headers="%s",signature="%s"`, strings.Join(components, " "), base64.StdEncoding.EncodeToString(signature))
+	httpRequest.Header.Set("Signature", header)
+	return nil
+}
+
+func (signer *HTTPSigner) components() []string {
+	if len(signer.Components) > 0 {
+		return signer.Components
+	}
+	if signer.Scheme == SignatureRFC9421 {
+		return rfc9421Components
+	}
+	return cavageComponents
+}
+
+func (signer *HTTPSigner) label() string {
+	if signer.Label == "" {
+		return "sig1"
+	}
+	return signer.Label
+}
+
+// VerifyHTTPSignature checks the signature and the body digest of a received request, it is meant for tests and mock servers
+// Both Signature-Input (RFC 9421) and cavage style Signature headers are supported
+func VerifyHTTPSignature(httpRequest *http.Request, publicKey crypto.PublicKey) error {
+	var body []byte
+	if httpRequest.Body != nil {
+		b, err := ioutil.ReadAll(httpRequest.Body)
+		if err != nil {
+			return err
+		}
+		httpRequest.Body.Close()
+		httpRequest.Body = ioutil.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	if err := verifyDigest(httpRequest, body); err != nil {
+		return err
+	}
+
+	if input := httpRequest.Header.Get("Signature-Input"); input != "" {
+		return verifyRFC9421(httpRequest, publicKey, input)
+	}
+	return verifyCavage(httpRequest, publicKey)
+}
+
+func verifyDigest(httpRequest *http.Request, body []byte) error {
+	sum := sha256.Sum256(body)
+	encoded := base64.StdEncoding.EncodeToString(sum[:])
+	if digest := httpRequest.Header.Get("Digest"); digest != "" && digest != "SHA-256="+encoded {
+		return errors.New("digest header does not match the body")
+	}
+	if digest := httpRequest.Header.Get("Content-Digest"); digest != "" && digest != "sha-256=:"+encoded+":" {
+		return errors.New("content-digest header does not match the body")
+	}
+	return nil
+}
+
+func verifyRFC9421(httpRequest *http.Request, publicKey crypto.PublicKey, input string) error {
+	eq := strings.Index(input, "=")
+	if eq < 0 {
+		return errors.New("malformed Signature-Input header")
+	}
+	label, params := input[:eq], input[eq+1:]
+
+	end := strings.Index(params, ")")
+	if !strings.HasPrefix(params, "(") || end < 0 {
+		return errors.New("malformed Signature-Input component list")
+	}
+	var components []string
+	for _, component := range strings.Fields(params[1:end]) {
+		components = append(components, strings.Trim(component, `"`))
+	}
+
+	signature := httpRequest.Header.Get("Signature")
+	prefix := label + "=:"
+	if !strings.HasPrefix(signature, prefix) || !strings.HasSuffix(signature, ":") {
+		return errors.New("signature header has no value for label " + label)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature[len(prefix) : len(signature)-1])
+	if err != nil {
+		return err
+	}
+
+	base, err := rfc9421Base(httpRequest, components, params)
+	if err != nil {
+		return err
+	}
+	return verifyMessage(publicKey, SignatureRFC9421, []byte(base), decoded)
+}
+
+func verifyCavage(httpRequest *http.Request, publicKey crypto.PublicKey) error {
+	header := httpRequest.Header.Get("Signature")
+	if header == "" {
+		return errors.New("signature header is missing")
+	}
+
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		eq := strings.Index(part, "=")
+		if eq < 0 {
+			return errors.New("malformed signature header")
+		}
+		params[strings.TrimSpace(part[:eq])] = strings.Trim(part[eq+1:], `"`)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return err
+	}
+	components := strings.Fields(params["headers"])
+	if len(components) == 0 {
+		components = []string{"date"}
+	}
+	var created int64
+	if params["created"] != "" {
+		if created, err = strconv.ParseInt(params["created"], 10, 64); err != nil {
+			return err
+		}
+	}
+
+	base, err := cavageBase(httpRequest, components, created)
+	if err != nil {
+		return err
+	}
+	return verifyMessage(publicKey, SignatureCavage, []byte(base), decoded)
+}
+
+// cavageBase builds the signing string of draft-cavage-http-signatures-12 section 2.3
+func cavageBase(httpRequest *http.Request, components []string, created int64) (string, error) {
+	lines := make([]string, 0, len(components))
+	for _, component := range components {
+		var value string
+		switch component {
+		case "(request-target)":
+			value = strings.ToLower(httpRequest.Method) + " " + httpRequest.URL.RequestURI()
+		case "(created)":
+			value = strconv.FormatInt(created, 10)
+		case "host":
+			value = requestHost(httpRequest)
+		default:
+			values, ok := httpRequest.Header[http.CanonicalHeaderKey(component)]
+			if !ok {
+				return "", fmt.Errorf("signature component %q is missing", component)
+			}
+			value = strings.Join(values, ", ")
+		}
+		lines = append(lines, component+": "+value)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// rfc9421Base builds the signature base of RFC 9421 section 2.5, params is the serialized @signature-params value
+func rfc9421Base(httpRequest *http.Request, components []string, params string) (string, error) {
+	var builder strings.Builder
+	for _, component := range components {
+		var value string
+		switch component {
+		case "@method":
+			value = strings.ToUpper(httpRequest.Method)
+		case "@target-uri":
+			value = requestScheme(httpRequest) + "://" + requestHost(httpRequest) + httpRequest.URL.RequestURI()
+		case "@authority":
+			value = strings.ToLower(requestHost(httpRequest))
+		case "@scheme":
+			value = requestScheme(httpRequest)
+		case "@request-target":
+			value = httpRequest.URL.RequestURI()
+		case "@path":
+			value = httpRequest.URL.EscapedPath()
+			if value == "" {
+				value = "/"
+			}
+		case "@query":
+			value = "?" + httpRequest.URL.RawQuery
+		default:
+			if strings.HasPrefix(component, "@") {
+				return "", fmt.Errorf("signature component %q is not supported", component)
+			}
+			values, ok := httpRequest.Header[http.CanonicalHeaderKey(component)]
+			if !ok {
+				return "", fmt.Errorf("signature component %q is missing", component)
+			}
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.TrimSpace(v)
+			}
+			value = strings.Join(trimmed, ", ")
+		}
+		fmt.Fprintf(&builder, "%q: %s\n", strings.ToLower(component), value)
+	}
+	fmt.Fprintf(&builder, "%q: %s", "@signature-params", params)
+	return builder.String(), nil
+}
+
+func signatureParams(components []string, created int64, keyID, algorithm string) string {
+	quoted := make([]string, len(components))
+	for i, component := range components {
+		quoted[i] = strconv.Quote(strings.ToLower(component))
+	}
+	return fmt.Sprintf(`(%s);created=%d;keyid="%s";alg="%s"`, strings.Join(quoted, " "), created, keyID, algorithm)
+}
+
+func requestHost(httpRequest *http.Request) string {
+	if httpRequest.Host != "" {
+		return httpRequest.Host
+	}
+	return httpRequest.URL.Host
+}
+
+func requestScheme(httpRequest *http.Request) string {
+	if httpRequest.URL.Scheme != "" {
+		return httpRequest.URL.Scheme
+	}
+	if httpRequest.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func signatureAlgorithm(publicKey crypto.PublicKey, scheme SignatureScheme) (string, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if scheme == SignatureRFC9421 {
+			return "rsa-v1_5-sha256", nil
+		}
+		return "rsa-sha256", nil
+	case *ecdsa.PublicKey:
+		if scheme == SignatureCavage {
+			return "ecdsa-sha256", nil
+		}
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return "ecdsa-p256-sha256", nil
+		case 384:
+			return "ecdsa-p384-sha384", nil
+		}
+		return "", errors.New("only P-256 and P-384 ecdsa keys are supported")
+	case ed25519.PublicKey:
+		if scheme == SignatureCavage {
+			return "hs2019", nil
+		}
+		return "ed25519", nil
+	}
+	return "", fmt.Errorf("unsupported signature key type %T", publicKey)
+}
+
+// ecdsaSignature is the ASN.1 form of an ecdsa signature used by the cavage scheme
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// ecdsaHash returns the digest used for the curve of key, RFC 9421 pairs P-384 with SHA-384
+func ecdsaHash(key *ecdsa.PublicKey, scheme SignatureScheme, message []byte) []byte {
+	if scheme == SignatureRFC9421 && key.Curve.Params().BitSize == 384 {
+		sum := sha512.Sum384(message)
+		return sum[:]
+	}
+	sum := sha256.Sum256(message)
+	return sum[:]
+}
+
+func signMessage(key crypto.Signer, scheme SignatureScheme, message []byte) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256(message)
+		return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, ecdsaHash(&k.PublicKey, scheme, message))
+		if err != nil {
+			return nil, err
+		}
+		if scheme == SignatureCavage {
+			return asn1.Marshal(ecdsaSignature{R: r, S: s})
+		}
+		// RFC 9421 uses the fixed size r||s encoding
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		copy(signature[size-len(rBytes):size], rBytes)
+		copy(signature[2*size-len(sBytes):], sBytes)
+		return signature, nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, message), nil
+	}
+	return nil, fmt.Errorf("unsupported signature key type %T", key)
+}
+
+func verifyMessage(publicKey crypto.PublicKey, scheme SignatureScheme, message, signature []byte) error {
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(message)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], signature)
+	case *ecdsa.PublicKey:
+		var r, s *big.Int
+		if scheme == SignatureCavage {
+			parsed := ecdsaSignature{}
+			if _, err := asn1.Unmarshal(signature, &parsed); err != nil {
+				return err
+			}
+			r, s = parsed.R, parsed.S
+		} else {
+			size := len(signature) / 2
+			r, s = new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		}
+		if !ecdsa.Verify(k, ecdsaHash(k, scheme, message), r, s) {
+			return errors.New("ecdsa signature verification failed")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, message, signature) {
+			return errors.New("ed25519 signature verification failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported signature key type %T", publicKey)
+}
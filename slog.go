@@ -0,0 +1,39 @@
+//go:build go1.21
+// +build go1.21
+
+package tinyclient
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger sends log entries to a log/slog handler
+type SlogLogger struct {
+	Handler slog.Handler
+}
+
+// NewSlogLogger creates a new SlogLogger, use logger.Handler() to log through a *slog.Logger
+func NewSlogLogger(handler slog.Handler) *SlogLogger {
+	return &SlogLogger{Handler: handler}
+}
+
+func (logger *SlogLogger) Log(level Level, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+	slog.New(logger.Handler).LogAttrs(context.Background(), slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
@@ -0,0 +1,150 @@
+package interview_accountapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendAsync(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		rw.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	client := tiny.NewClient()
+	future := client.SendAsync(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+
+	select {
+	case <-future.Done():
+		t.Fatal("future is done before the response")
+	default:
+	}
+
+	response, err := future.Result()
+	require.NoError(t, err)
+	body, err := response.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, "done", string(body))
+}
+
+func TestSendAll(t *testing.T) {
+
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		body, _ := ioutil.ReadAll(req.Body)
+		rw.Write(body)
+	}))
+	defer server.Close()
+
+	client := tiny.NewClient()
+	var requests []*tiny.Request
+	for i := 0; i < 20; i++ {
+		requests = append(requests, client.NewRequest().SetURL(server.URL).SetMethod(tiny.Post).SetBody(fmt.Sprint(i)))
+	}
+
+	var progress []int
+	results, err := client.SendAll(context.Background(), requests, tiny.BatchOptions{
+		Parallelism: 3,
+		OnProgress: func(result tiny.BatchResult, completed, total int) {
+			require.Equal(t, 20, total)
+			progress = append(progress, completed)
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 20)
+	require.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(3))
+	require.Len(t, progress, 20)
+	require.Equal(t, 20, progress[19])
+
+	// Results keep the order of the requests
+	for i, result := range results {
+		require.Equal(t, i, result.Index)
+		body, err := result.Response.ReadBody()
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprint(i), string(body))
+	}
+}
+
+func TestSendAllErrorModes(t *testing.T) {
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	closed.Close()
+
+	client := tiny.NewClient()
+	newRequests := func() []*tiny.Request {
+		var requests []*tiny.Request
+		for i := 0; i < 10; i++ {
+			url := server.URL
+			if i == 1 || i == 5 {
+				url = closed.URL
+			}
+			requests = append(requests, client.NewRequest().SetURL(url).SetMethod(tiny.Get))
+		}
+		return requests
+	}
+
+	results, err := client.SendAll(context.Background(), newRequests(), tiny.BatchOptions{Parallelism: 1})
+	var batchError *tiny.BatchError
+	require.True(t, errors.As(err, &batchError), err)
+	require.Equal(t, []int{1, 5}, batchError.Failed)
+	require.Len(t, results, 10)
+	require.Equal(t, int32(8), atomic.LoadInt32(&calls))
+
+	// Fail fast skips the requests after the first failure
+	atomic.StoreInt32(&calls, 0)
+	results, err = client.SendAll(context.Background(), newRequests(), tiny.BatchOptions{Parallelism: 1, Mode: tiny.FailFast})
+	require.Error(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.True(t, errors.Is(results[9].Err, context.Canceled), results[9].Err)
+}
+
+func TestSendAllStreamedBody(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("first "))
+		rw.(http.Flusher).Flush()
+		// The rest of the body arrives after SendAll returned
+		time.Sleep(50 * time.Millisecond)
+		rw.Write([]byte("second"))
+	}))
+	defer server.Close()
+
+	client := tiny.NewClient()
+	requests := []*tiny.Request{
+		client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get),
+		client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get),
+	}
+	results, err := client.SendAll(context.Background(), requests, tiny.BatchOptions{})
+	require.NoError(t, err)
+
+	for _, result := range results {
+		body, err := result.Response.ReadBody()
+		require.NoError(t, err)
+		require.Equal(t, "first second", string(body))
+	}
+}
@@ -0,0 +1,261 @@
+package interview_accountapi_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readAuditRecords(t *testing.T, data []byte) []tiny.AuditRecord {
+	var records []tiny.AuditRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var record tiny.AuditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditSink(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte(`{"data":{"id":"1"}}`))
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	closed.Close()
+
+	var buffer bytes.Buffer
+	sink := tiny.NewAuditSink(&buffer, 10)
+	client := tiny.NewClient().SetLogger(tiny.NopLogger).SetAuditSink(sink).
+		SetAuthenticator(tiny.NewBearerToken("secret-token"))
+
+	request := client.NewRequest().SetURL(server.URL + "/accounts").SetMethod(tiny.Post).
+		SetContentType(tiny.JsonContentType).
+		SetBody(map[string]string{"name": "Samantha"})
+	response, err := client.Send(request)
+	require.NoError(t, err)
+
+	// The audited body can still be read from the http.Response
+	body, err := ioutil.ReadAll(response.Response.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"data":{"id":"1"}}`, string(body))
+
+	_, err = client.Send(client.NewRequest().SetURL(closed.URL).SetMethod(tiny.Get))
+	require.Error(t, err)
+
+	require.NoError(t, sink.Close())
+	records := readAuditRecords(t, buffer.Bytes())
+	require.Len(t, records, 2)
+
+	require.Equal(t, "POST", records[0].Method)
+	require.Equal(t, server.URL+"/accounts", records[0].URL)
+	require.Equal(t, tiny.RedactedValue, records[0].RequestHeaders.Get("Authorization"))
+	require.Equal(t, `{"name":"Samantha"}`, records[0].RequestBody)
+	require.Equal(t, http.StatusCreated, records[0].Status)
+	require.Equal(t, `{"data":{"id":"1"}}`, records[0].ResponseBody)
+	require.NotNil(t, records[0].Timings)
+	require.False(t, records[0].Timestamp.IsZero())
+
+	require.Equal(t, "GET", records[1].Method)
+	require.Zero(t, records[1].Status)
+	require.NotEmpty(t, records[1].Error)
+
+	// Records after Close are dropped
+	_, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	require.Len(t, readAuditRecords(t, buffer.Bytes()), 2)
+}
+
+func TestAuditFileRotation(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(strings.Repeat("x", 200)))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "exchanges.jsonl")
+
+	sink, err := tiny.NewAuditFile(filename, 1024, 2)
+	require.NoError(t, err)
+	client := tiny.NewClient().SetAuditSink(sink)
+	for i := 0; i < 20; i++ {
+		response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+		require.NoError(t, err)
+		// The record is written once the body is read
+		_, err = response.ReadBody()
+		require.NoError(t, err)
+		require.NoError(t, sink.Flush())
+	}
+	require.NoError(t, sink.Close())
+
+	files, err := filepath.Glob(filename + "*")
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		require.True(t, len(data) <= 1024, file)
+		// Every file has whole records only
+		require.NotEmpty(t, readAuditRecords(t, data))
+	}
+}
+
+func TestAuditBodyExcerpt(t *testing.T) {
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		rw.(http.Flusher).Flush()
+		<-release
+		rw.Write([]byte(strings.Repeat("x", 100) + strings.Repeat("y", 100)))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var buffer bytes.Buffer
+	sink := tiny.NewAuditSink(&buffer, 10)
+	require.Equal(t, tiny.DefaultAuditBodyLength, sink.MaxBodyLength)
+	sink.MaxBodyLength = 16
+	client := tiny.NewClient().SetLogger(tiny.NopLogger).SetAuditSink(sink)
+
+	// Send returns before any of the body is sent, nothing is read ahead of the caller
+	response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Post).
+		SetBody([]byte(strings.Repeat("z", 20))))
+	require.NoError(t, err)
+	require.NoError(t, sink.Flush())
+	require.Zero(t, buffer.Len())
+	release <- struct{}{}
+	body, err := ioutil.ReadAll(response.Response.Body)
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("x", 100)+strings.Repeat("y", 100), string(body))
+	require.NoError(t, response.Response.Body.Close())
+
+	require.NoError(t, sink.Close())
+	records := readAuditRecords(t, buffer.Bytes())
+	require.Len(t, records, 1)
+	require.Equal(t, strings.Repeat("z", 16)+"... (4 bytes truncated)", records[0].RequestBody)
+	require.True(t, records[0].RequestBodyTruncated)
+	require.Equal(t, strings.Repeat("x", 16)+"... (truncated)", records[0].ResponseBody)
+	require.True(t, records[0].ResponseBodyTruncated)
+	require.False(t, records[0].ResponseBodyRedacted)
+}
+
+func TestAuditBodyExcerptRedacted(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"data":{"iban":"GB29NWBK60161331926819","name":"` + strings.Repeat("x", 100) + `"}}`))
+	}))
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	sink := tiny.NewAuditSink(&buffer, 10)
+	sink.MaxBodyLength = 64
+	client := tiny.NewClient().SetLogger(tiny.NopLogger).SetAuditSink(sink).
+		SetRedactor(tiny.NewRedactor().AddJSONFields("data.iban"))
+
+	response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Post).
+		SetContentType(tiny.JsonContentType).SetBody(map[string]string{"id": "1"}))
+	require.NoError(t, err)
+	_, err = response.ReadBody()
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Close())
+	records := readAuditRecords(t, buffer.Bytes())
+	require.Len(t, records, 1)
+	// A cut JSON document can't be masked, so nothing of it is recorded
+	require.Empty(t, records[0].ResponseBody)
+	require.True(t, records[0].ResponseBodyTruncated)
+	require.True(t, records[0].ResponseBodyRedacted)
+	require.Equal(t, `{"id":"1"}`, records[0].RequestBody)
+	require.False(t, records[0].RequestBodyRedacted)
+}
+
+func TestAuditErrorRedacted(t *testing.T) {
+
+	closed := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	closed.Close()
+
+	var buffer bytes.Buffer
+	sink := tiny.NewAuditSink(&buffer, 10)
+	client := tiny.NewClient().SetLogger(tiny.NopLogger).SetAuditSink(sink)
+
+	_, err := client.Send(client.NewRequest().SetURL(closed.URL + "/accounts?token=secret-token").SetMethod(tiny.Get))
+	require.Error(t, err)
+
+	require.NoError(t, sink.Close())
+	records := readAuditRecords(t, buffer.Bytes())
+	require.Len(t, records, 1)
+	require.NotEmpty(t, records[0].Error)
+	require.NotContains(t, records[0].Error, "secret-token")
+	require.NotContains(t, records[0].URL, "secret-token")
+}
+
+func TestAuditFileRotationFailure(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "exchanges.jsonl")
+
+	// The current file can't be renamed over a directory which isn't empty
+	require.NoError(t, os.MkdirAll(filepath.Join(filename+".1", "keep"), 0755))
+
+	file, err := tiny.NewRotatingFile(filename, 10, 1)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.Write([]byte("first line\n"))
+	require.NoError(t, err)
+
+	// Writing goes on to the current file after a failed rotation
+	n, err := file.Write([]byte("second line\n"))
+	require.Error(t, err)
+	require.Equal(t, len("second line\n"), n)
+	_, err = file.Write([]byte("third line\n"))
+	require.Error(t, err)
+
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "first line\nsecond line\nthird line\n", string(data))
+}
+
+func TestAuditConflictRedacted(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusConflict)
+		rw.Write([]byte(`{"error_message":"version mismatch"}`))
+	}))
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	sink := tiny.NewAuditSink(&buffer, 10)
+	client := tiny.NewClient().SetLogger(tiny.NopLogger).SetAuditSink(sink).
+		SetAuthenticator(tiny.NewAPIKey("api_key", "SUPERSECRET", tiny.APIKeyInQuery))
+
+	response, err := client.Send(client.NewRequest().SetURL(server.URL + "/acc").SetMethod(tiny.Delete).IfVersion(0))
+	require.True(t, errors.Is(err, tiny.ErrConflict))
+	require.NoError(t, response.Response.Body.Close())
+
+	require.NoError(t, sink.Close())
+	require.NotContains(t, buffer.String(), "SUPERSECRET")
+	records := readAuditRecords(t, buffer.Bytes())
+	require.Len(t, records, 1)
+	require.Equal(t, http.StatusConflict, records[0].Status)
+	require.Contains(t, records[0].URL, "api_key=[REDACTED]")
+	require.Contains(t, records[0].Error, "api_key=[REDACTED]")
+}
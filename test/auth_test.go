@@ -0,0 +1,122 @@
+package interview_accountapi_test
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticators(t *testing.T) {
+
+	var table = []struct {
+		name          string
+		authenticator tiny.Authenticator
+		check         func(req *http.Request)
+	}{
+		{"basic", tiny.NewBasicAuth("user", "pass"), func(req *http.Request) {
+			username, password, ok := req.BasicAuth()
+			require.True(t, ok)
+			require.Equal(t, "user", username)
+			require.Equal(t, "pass", password)
+		}},
+		{"bearer", tiny.NewBearerToken("secret-token"), func(req *http.Request) {
+			require.Equal(t, "Bearer secret-token", req.Header.Get("Authorization"))
+		}},
+		{"apikey-header", tiny.NewAPIKey("X-Api-Key", "key", tiny.APIKeyInHeader), func(req *http.Request) {
+			require.Equal(t, "key", req.Header.Get("X-Api-Key"))
+		}},
+		{"apikey-query", tiny.NewAPIKey("api_key", "key", tiny.APIKeyInQuery), func(req *http.Request) {
+			require.Equal(t, "key", req.URL.Query().Get("api_key"))
+			require.Equal(t, "value1", req.URL.Query().Get("param1"))
+		}},
+	}
+
+	for _, row := range table {
+		server := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				row.check(req)
+			}),
+		)
+
+		client := tiny.NewClient().SetAuthenticator(row.authenticator)
+		request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get).
+			AddQueryParam("param1", "value1")
+
+		response, err := client.Send(request)
+		server.Close()
+
+		require.NoError(t, err, row.name)
+		require.Equal(t, 200, response.Response.StatusCode, row.name)
+	}
+}
+
+func TestAuthenticatorRequestOverride(t *testing.T) {
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/override":
+				require.Equal(t, "Bearer request-token", req.Header.Get("Authorization"))
+			case "/none":
+				require.Empty(t, req.Header.Get("Authorization"))
+			}
+		}),
+	)
+	defer server.Close()
+
+	client := tiny.NewClient().SetAuthenticator(tiny.NewBasicAuth("user", "pass"))
+
+	request := client.NewRequest().SetURL(server.URL + "/override").SetMethod(tiny.Get).
+		SetAuthenticator(tiny.NewBearerToken("request-token"))
+	_, err := client.Send(request)
+	require.NoError(t, err)
+
+	request = client.NewRequest().SetURL(server.URL + "/none").SetMethod(tiny.Get).
+		SetAuthenticator(tiny.NoAuth)
+	_, err = client.Send(request)
+	require.NoError(t, err)
+}
+
+func TestAuthenticatorStrippedOnCrossHostRedirect(t *testing.T) {
+
+	otherHost := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			require.Empty(t, req.Header.Get("Authorization"))
+			require.Empty(t, req.Header.Get("X-Api-Key"))
+			require.Empty(t, req.Header.Get("Cookie"))
+			require.Equal(t, "kept", req.Header.Get("Test-Header"))
+		}),
+	)
+	defer otherHost.Close()
+
+	sameHost := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/same":
+				sameHost++
+				require.Equal(t, "key", req.Header.Get("X-Api-Key"))
+				http.Redirect(rw, req, "/final", http.StatusFound)
+			case "/final":
+				require.Equal(t, "key", req.Header.Get("X-Api-Key"))
+				http.Redirect(rw, req, otherHost.URL+"/other", http.StatusFound)
+			}
+		}),
+	)
+	defer server.Close()
+
+	client := tiny.NewClient().SetAuthenticator(tiny.NewAPIKey("X-Api-Key", "key", tiny.APIKeyInHeader))
+	client.Cookies = append(client.Cookies, &http.Cookie{Name: "session", Value: "secret"})
+
+	request := client.NewRequest().SetURL(fmt.Sprintf("%s/same", server.URL)).SetMethod(tiny.Get).
+		AddHeader("Test-Header", "kept")
+
+	response, err := client.Send(request)
+
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+	require.Equal(t, 1, sameHost)
+}
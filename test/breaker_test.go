@@ -0,0 +1,129 @@
+package interview_accountapi_test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+
+	var failing int32 = 1
+	var hits int32
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			if atomic.LoadInt32(&failing) == 1 {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}),
+	)
+	defer server.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer healthy.Close()
+
+	var mu sync.Mutex
+	var changes []string
+	breaker := tiny.NewCircuitBreaker(0.5, 100*time.Millisecond)
+	breaker.MinRequests = 3
+	breaker.OnStateChange = func(key string, from, to tiny.CircuitState) {
+		mu.Lock()
+		changes = append(changes, from.String()+"->"+to.String())
+		mu.Unlock()
+	}
+	client := tiny.NewClient().SetCircuitBreaker(breaker)
+
+	for i := 0; i < 3; i++ {
+		response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, response.Response.StatusCode)
+	}
+
+	serverURL, _ := url.Parse(server.URL)
+	require.Equal(t, tiny.CircuitOpen, breaker.State(serverURL.Host))
+
+	// Open circuit fails fast without reaching the server
+	_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.True(t, errors.Is(err, tiny.ErrCircuitOpen))
+	require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+	// Other hosts have their own circuit
+	_, err = client.Send(client.NewRequest().SetURL(healthy.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+
+	// A failed trial after the cool down opens the circuit again
+	time.Sleep(120 * time.Millisecond)
+	_, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	require.Equal(t, tiny.CircuitOpen, breaker.State(serverURL.Host))
+
+	// A successful trial closes it
+	atomic.StoreInt32(&failing, 0)
+	time.Sleep(120 * time.Millisecond)
+	response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+	require.Equal(t, tiny.CircuitClosed, breaker.State(serverURL.Host))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}, changes)
+}
+
+func TestCircuitBreakerByRoute(t *testing.T) {
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/v1/health" {
+				rw.WriteHeader(http.StatusInternalServerError)
+			}
+		}),
+	)
+	defer server.Close()
+
+	breaker := tiny.NewCircuitBreaker(1, time.Minute)
+	breaker.MinRequests = 2
+	breaker.KeyFunc = tiny.KeyByRoute
+	client := tiny.NewClient().SetCircuitBreaker(breaker)
+
+	for _, id := range []string{"1", "2"} {
+		_, err := client.Send(client.NewRequest().SetURL(server.URL + "/v1/accounts/" + id).SetMethod(tiny.Get).
+			SetRoute("/v1/accounts/{id}"))
+		require.NoError(t, err)
+	}
+
+	_, err := client.Send(client.NewRequest().SetURL(server.URL + "/v1/accounts/3").SetMethod(tiny.Get).
+		SetRoute("/v1/accounts/{id}"))
+	require.True(t, errors.Is(err, tiny.ErrCircuitOpen))
+
+	_, err = client.Send(client.NewRequest().SetURL(server.URL + "/v1/health").SetMethod(tiny.Get).
+		SetRoute("/v1/health"))
+	require.NoError(t, err)
+}
+
+func TestCircuitBreakerNetworkErrors(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	server.Close()
+
+	breaker := tiny.NewCircuitBreaker(0.5, time.Minute)
+	breaker.MinRequests = 2
+	client := tiny.NewClient().SetCircuitBreaker(breaker)
+
+	// Connection errors count as failures
+	for i := 0; i < 2; i++ {
+		_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+		require.Error(t, err)
+		require.False(t, errors.Is(err, tiny.ErrCircuitOpen), err)
+	}
+	_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.True(t, errors.Is(err, tiny.ErrCircuitOpen), err)
+}
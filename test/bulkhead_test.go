@@ -0,0 +1,117 @@
+package interview_accountapi_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBulkhead(t *testing.T) {
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	bulkhead := tiny.NewBulkhead(2, 1, 0)
+	client := tiny.NewClient().SetBulkhead(bulkhead)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+			require.NoError(t, err)
+			// The slot is freed when the body is read and closed
+			_, err = response.ReadBody()
+			require.NoError(t, err)
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return bulkhead.Stats("") == tiny.BulkheadStats{InFlight: 2, Queued: 1}
+	}, time.Second, 5*time.Millisecond)
+
+	// Two in flight and one queued, the next request is rejected at once
+	_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.True(t, errors.Is(err, tiny.ErrBulkheadFull), err)
+
+	close(release)
+	wg.Wait()
+	require.Equal(t, tiny.BulkheadStats{}, bulkhead.Stats(""))
+}
+
+func TestBulkheadQueueTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	other := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer other.Close()
+
+	bulkhead := tiny.NewBulkhead(1, 5, 50*time.Millisecond)
+	bulkhead.KeyFunc = tiny.KeyByHost
+	client := tiny.NewClient().SetBulkhead(bulkhead)
+
+	go client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	host, _ := url.Parse(server.URL)
+	require.Eventually(t, func() bool {
+		return bulkhead.Stats(host.Host).InFlight == 1
+	}, time.Second, 5*time.Millisecond)
+
+	start := time.Now()
+	_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.True(t, errors.Is(err, tiny.ErrBulkheadTimeout), err)
+	require.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// A cancelled context leaves the queue before the timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled := tiny.NewClient().SetBulkhead(bulkhead).SetContext(ctx)
+	_, err = cancelled.Send(cancelled.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.True(t, errors.Is(err, context.Canceled), err)
+	require.Equal(t, 0, bulkhead.Stats(host.Host).Queued)
+
+	// Another host has its own compartment
+	_, err = client.Send(client.NewRequest().SetURL(other.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	require.Len(t, bulkhead.Keys(), 2)
+}
+
+func TestBulkheadHoldsSlotUntilBodyClosed(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	bulkhead := tiny.NewBulkhead(1, 0, 0)
+	client := tiny.NewClient().SetBulkhead(bulkhead)
+
+	response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+
+	// The body isn't read yet, its connection still counts as in flight
+	require.Equal(t, 1, bulkhead.Stats("").InFlight)
+	_, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.True(t, errors.Is(err, tiny.ErrBulkheadFull), err)
+
+	body, err := response.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, "body", string(body))
+	require.Equal(t, tiny.BulkheadStats{}, bulkhead.Stats(""))
+
+	_, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+}
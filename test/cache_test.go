@@ -0,0 +1,308 @@
+package interview_accountapi_test
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const lastModified = "Mon, 07 Jun 2021 10:00:00 GMT"
+
+// newCacheServer serves reference data with different caching headers and counts the requests per path
+func newCacheServer(t *testing.T, hits map[string]*int32) *httptest.Server {
+	for _, path := range []string{"/max-age", "/etag", "/last-modified", "/no-store", "/vary", "/swr"} {
+		hits[path] = new(int32)
+	}
+	return httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			count := int32(0)
+			if hit, ok := hits[req.URL.Path]; ok && req.Method == "GET" {
+				count = atomic.AddInt32(hit, 1)
+			}
+			switch req.URL.Path {
+			case "/max-age":
+				rw.Header().Set("Cache-Control", "max-age=60")
+			case "/etag":
+				rw.Header().Set("Cache-Control", "no-cache")
+				rw.Header().Set("ETag", `"v1"`)
+				if req.Header.Get("If-None-Match") == `"v1"` {
+					rw.WriteHeader(http.StatusNotModified)
+					return
+				}
+			case "/last-modified":
+				rw.Header().Set("Cache-Control", "max-age=0")
+				rw.Header().Set("Last-Modified", lastModified)
+				if req.Header.Get("If-Modified-Since") == lastModified {
+					rw.WriteHeader(http.StatusNotModified)
+					return
+				}
+			case "/no-store":
+				rw.Header().Set("Cache-Control", "no-store, max-age=60")
+			case "/vary":
+				rw.Header().Set("Cache-Control", "max-age=60")
+				rw.Header().Set("Vary", "Accept")
+				fmt.Fprintf(rw, "%s", req.Header.Get("Accept"))
+				return
+			case "/swr":
+				rw.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+			}
+			fmt.Fprintf(rw, "%s %d", req.URL.Path, count)
+		}),
+	)
+}
+
+func sendCached(t *testing.T, client *tiny.Client, url string, headers map[string]string) (string, bool) {
+	request := client.NewRequest().SetURL(url).SetMethod(tiny.Get).SetHeaders(headers)
+	response, err := client.Send(request)
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+	body, err := response.ReadBody()
+	require.NoError(t, err)
+	return string(body), response.FromCache
+}
+
+func TestCacheFreshnessAndRevalidation(t *testing.T) {
+
+	hits := map[string]*int32{}
+	server := newCacheServer(t, hits)
+	defer server.Close()
+
+	client := tiny.NewClient().SetCache(tiny.NewMemoryCache(100))
+
+	var table = []struct {
+		path      string
+		fromCache bool
+		hits      int32
+	}{
+		{"/max-age", true, 1},
+		{"/etag", true, 2},
+		{"/last-modified", true, 2},
+		{"/no-store", false, 2},
+	}
+
+	for _, row := range table {
+		body, fromCache := sendCached(t, client, server.URL+row.path, nil)
+		require.False(t, fromCache, row.path)
+		require.Equal(t, row.path+" 1", body)
+
+		body, fromCache = sendCached(t, client, server.URL+row.path, nil)
+		require.Equal(t, row.fromCache, fromCache, row.path)
+		require.Equal(t, row.hits, atomic.LoadInt32(hits[row.path]), row.path)
+		if row.fromCache {
+			require.Equal(t, row.path+" 1", body, row.path)
+		}
+	}
+
+	// Request no-cache forces a revalidation of a fresh response
+	body, fromCache := sendCached(t, client, server.URL+"/max-age", map[string]string{"Cache-Control": "no-cache"})
+	require.False(t, fromCache)
+	require.Equal(t, "/max-age 2", body)
+
+	// Unsafe methods invalidate the cached response
+	request := client.NewRequest().SetURL(server.URL + "/max-age").SetMethod(tiny.Post)
+	_, err := client.Send(request)
+	require.NoError(t, err)
+	_, fromCache = sendCached(t, client, server.URL+"/max-age", nil)
+	require.False(t, fromCache)
+}
+
+func TestCacheVary(t *testing.T) {
+
+	hits := map[string]*int32{}
+	server := newCacheServer(t, hits)
+	defer server.Close()
+
+	client := tiny.NewClient().SetCache(tiny.NewMemoryCache(100))
+
+	body, fromCache := sendCached(t, client, server.URL+"/vary", map[string]string{"Accept": "application/json"})
+	require.Equal(t, "application/json", body)
+	require.False(t, fromCache)
+
+	body, fromCache = sendCached(t, client, server.URL+"/vary", map[string]string{"Accept": "text/csv"})
+	require.Equal(t, "text/csv", body)
+	require.False(t, fromCache)
+
+	body, fromCache = sendCached(t, client, server.URL+"/vary", map[string]string{"Accept": "text/csv"})
+	require.Equal(t, "text/csv", body)
+	require.True(t, fromCache)
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+
+	hits := map[string]*int32{}
+	server := newCacheServer(t, hits)
+	defer server.Close()
+
+	client := tiny.NewClient().SetCache(tiny.NewMemoryCache(100))
+
+	body, _ := sendCached(t, client, server.URL+"/swr", nil)
+	require.Equal(t, "/swr 1", body)
+
+	// Stale response is served at once and refreshed in background
+	body, fromCache := sendCached(t, client, server.URL+"/swr", nil)
+	require.True(t, fromCache)
+	require.Equal(t, "/swr 1", body)
+
+	require.Eventually(t, func() bool {
+		body, _ := sendCached(t, client, server.URL+"/swr", nil)
+		return body == "/swr 2"
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestDiskCache(t *testing.T) {
+
+	hits := map[string]*int32{}
+	server := newCacheServer(t, hits)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "tinyclient-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	storage, err := tiny.NewDiskCache(dir)
+	require.NoError(t, err)
+	_, fromCache := sendCached(t, tiny.NewClient().SetCache(storage), server.URL+"/max-age", nil)
+	require.False(t, fromCache)
+
+	// Another client reads the same directory
+	storage, err = tiny.NewDiskCache(dir)
+	require.NoError(t, err)
+	body, fromCache := sendCached(t, tiny.NewClient().SetCache(storage), server.URL+"/max-age", nil)
+	require.True(t, fromCache)
+	require.Equal(t, "/max-age 1", body)
+	require.Equal(t, int32(1), atomic.LoadInt32(hits["/max-age"]))
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+
+	cache := tiny.NewMemoryCache(2)
+	require.NoError(t, cache.Set("a", []byte("1")))
+	require.NoError(t, cache.Set("b", []byte("2")))
+	_, ok := cache.Get("a")
+	require.True(t, ok)
+	require.NoError(t, cache.Set("c", []byte("3")))
+
+	_, ok = cache.Get("b")
+	require.False(t, ok)
+	_, ok = cache.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 2, cache.Len())
+}
+
+func TestCacheCredentials(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(rw, req.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	cache := tiny.NewMemoryCache(100)
+	alice := tiny.NewClient().SetCache(cache).SetAuthenticator(tiny.NewBearerToken("alice"))
+	bob := tiny.NewClient().SetCache(cache).SetAuthenticator(tiny.NewBearerToken("bob"))
+
+	body, fromCache := sendCached(t, alice, server.URL, nil)
+	require.False(t, fromCache)
+	require.Equal(t, "Bearer alice", body)
+
+	// The response cached for alice isn't served with other credentials
+	body, fromCache = sendCached(t, bob, server.URL, nil)
+	require.False(t, fromCache)
+	require.Equal(t, "Bearer bob", body)
+
+	body, fromCache = sendCached(t, alice, server.URL, nil)
+	require.True(t, fromCache)
+	require.Equal(t, "Bearer alice", body)
+	require.Equal(t, 2, cache.Len())
+}
+
+func TestCacheRevalidationLimits(t *testing.T) {
+
+	hits := map[string]*int32{}
+	server := newCacheServer(t, hits)
+	defer server.Close()
+
+	// The background revalidation takes a bulkhead slot like any request sent over the network
+	var acquired int32
+	bulkhead := tiny.NewBulkhead(1, 0, 0)
+	bulkhead.KeyFunc = func(request *tiny.Request) string {
+		atomic.AddInt32(&acquired, 1)
+		return ""
+	}
+	client := tiny.NewClient().SetCache(tiny.NewMemoryCache(100)).SetBulkhead(bulkhead)
+
+	sendCached(t, client, server.URL+"/swr", nil)
+	_, fromCache := sendCached(t, client, server.URL+"/swr", nil)
+	require.True(t, fromCache)
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(hits["/swr"]) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(2), atomic.LoadInt32(&acquired))
+}
+
+func TestCacheCallerValidators(t *testing.T) {
+
+	hits := map[string]*int32{}
+	server := newCacheServer(t, hits)
+	defer server.Close()
+
+	cache := tiny.NewMemoryCache(100)
+	client := tiny.NewClient().SetCache(cache)
+	body, fromCache := sendCached(t, client, server.URL+"/etag", nil)
+	require.False(t, fromCache)
+	require.Equal(t, "/etag 1", body)
+
+	// The 304 answering the caller's own validator is returned as is and the stored response is kept
+	response, err := client.Send(client.NewRequest().SetURL(server.URL + "/etag").SetMethod(tiny.Get).IfNoneMatch(`"v1"`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotModified, response.Response.StatusCode)
+	require.False(t, response.FromCache)
+	require.Equal(t, 1, cache.Len())
+
+	body, fromCache = sendCached(t, client, server.URL+"/etag", nil)
+	require.True(t, fromCache)
+	require.Equal(t, "/etag 1", body)
+}
+
+func TestCacheCookieJar(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Cache-Control", "max-age=60")
+		cookie, _ := req.Cookie("session")
+		fmt.Fprint(rw, cookie.Value)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	newSession := func(session string) *tiny.CookieJar {
+		jar := tiny.NewCookieJar()
+		jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: session}})
+		return jar
+	}
+	cache := tiny.NewMemoryCache(100)
+	alice := tiny.NewClient().SetCache(cache).SetCookieJar(newSession("alice"))
+	bob := tiny.NewClient().SetCache(cache).SetCookieJar(newSession("bob"))
+
+	body, fromCache := sendCached(t, alice, server.URL, nil)
+	require.False(t, fromCache)
+	require.Equal(t, "alice", body)
+
+	// Cookies of the jar are part of the key like the Cookie header
+	body, fromCache = sendCached(t, bob, server.URL, nil)
+	require.False(t, fromCache)
+	require.Equal(t, "bob", body)
+
+	body, fromCache = sendCached(t, alice, server.URL, nil)
+	require.True(t, fromCache)
+	require.Equal(t, "alice", body)
+}
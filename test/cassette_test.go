@@ -0,0 +1,162 @@
+package interview_accountapi_test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		body, _ := ioutil.ReadAll(req.Body)
+		http.SetCookie(rw, &http.Cookie{Name: "session", Value: "session-secret"})
+		rw.Header().Set("X-Request-Body", string(body))
+		rw.Write([]byte(req.Method + " " + req.URL.Path))
+	}))
+
+	dir, err := ioutil.TempDir("", "cassette")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "accounts.json")
+
+	cassette, err := tiny.NewCassette(path, tiny.CassetteRecord)
+	require.NoError(t, err)
+	cassette.SetMatchers(tiny.MatchMethod, tiny.MatchURL, tiny.MatchBody)
+	client := tiny.NewClient().SetCassette(cassette).SetAuthenticator(tiny.NewBearerToken("bearer-secret"))
+
+	send := func(client *tiny.Client, method tiny.Method, body string) (string, error) {
+		request := client.NewRequest().SetURL(server.URL + "/accounts").SetMethod(method)
+		if body != "" {
+			request.SetBody(body)
+		}
+		response, err := client.Send(request)
+		if err != nil {
+			return "", err
+		}
+		responseBody, err := response.ReadBody()
+		require.NoError(t, err)
+		return string(responseBody) + response.Response.Header.Get("X-Request-Body"), nil
+	}
+
+	first, err := send(client, tiny.Get, "")
+	require.NoError(t, err)
+	_, err = send(client, tiny.Post, "one")
+	require.NoError(t, err)
+	_, err = send(client, tiny.Post, "two")
+	require.NoError(t, err)
+	// A recorded interaction is replayed in record mode
+	again, err := send(client, tiny.Get, "")
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	require.Len(t, cassette.Interactions(), 3)
+
+	// Credentials and cookies are scrubbed from the file
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "bearer-secret")
+	require.NotContains(t, string(data), "session-secret")
+
+	// Replay only works without the server
+	server.Close()
+	replay, err := tiny.NewCassette(path, tiny.CassetteReplayOnly)
+	require.NoError(t, err)
+	replay.SetMatchers(tiny.MatchMethod, tiny.MatchURL, tiny.MatchBody)
+	client = tiny.NewClient().SetLogger(tiny.NopLogger).SetCassette(replay)
+
+	body, err := send(client, tiny.Post, "two")
+	require.NoError(t, err)
+	require.Equal(t, "POST /accountstwo", body)
+	_, err = send(client, tiny.Post, "three")
+	require.True(t, errors.Is(err, tiny.ErrInteractionNotFound), err)
+}
+
+func TestCassettePassthrough(t *testing.T) {
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cassette")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "passthrough.json")
+
+	cassette, err := tiny.NewCassette(path, tiny.CassettePassthrough)
+	require.NoError(t, err)
+	client := tiny.NewClient().SetCassette(cassette)
+	for i := 0; i < 2; i++ {
+		_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	// Replay only needs an existing cassette
+	_, err = tiny.NewCassette(path, tiny.CassetteReplayOnly)
+	require.Error(t, err)
+}
+
+func TestCassetteScrubQueryParams(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.URL.Query().Get("page")))
+	}))
+
+	dir, err := ioutil.TempDir("", "cassette")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "presigned.json")
+
+	cassette, err := tiny.NewCassette(path, tiny.CassetteRecord)
+	require.NoError(t, err)
+	cassette.AddScrubQueryParams("session")
+	client := tiny.NewClient().SetLogger(tiny.NopLogger).SetCassette(cassette)
+
+	send := func(client *tiny.Client, query string) (string, error) {
+		response, err := client.Send(client.NewRequest().SetURL(server.URL + "/reports?" + query).SetMethod(tiny.Get))
+		if err != nil {
+			return "", err
+		}
+		body, err := response.ReadBody()
+		require.NoError(t, err)
+		return string(body), nil
+	}
+
+	body, err := send(client, "page=1&token=token-secret&session=session-secret&X-Amz-Signature=signature-secret&X-Amz-Credential=credential-secret")
+	require.NoError(t, err)
+	require.Equal(t, "1", body)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"token-secret", "session-secret", "signature-secret", "credential-secret"} {
+		require.NotContains(t, string(data), secret)
+	}
+	require.Contains(t, string(data), "page=1")
+
+	// Requests are matched on the scrubbed URL, so new credentials still replay the recording
+	server.Close()
+	replay, err := tiny.NewCassette(path, tiny.CassetteReplayOnly)
+	require.NoError(t, err)
+	replay.AddScrubQueryParams("session")
+	client = tiny.NewClient().SetLogger(tiny.NopLogger).SetCassette(replay)
+
+	body, err = send(client, "page=1&token=other&session=other&X-Amz-Signature=other&X-Amz-Credential=other")
+	require.NoError(t, err)
+	require.Equal(t, "1", body)
+	_, err = send(client, "page=2&token=other&session=other&X-Amz-Signature=other&X-Amz-Credential=other")
+	require.True(t, errors.Is(err, tiny.ErrInteractionNotFound), err)
+}
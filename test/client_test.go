@@ -339,8 +339,8 @@ func TestPostLoggerInject(t *testing.T) {
 	//errorLogger:= log.New(errorLog, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 	client := tiny.NewClient().SetTimeout(30)
-	client.InfoLogger = infoLogger
-	//client.ErrorLogger = errorLogger
+	client.SetLogger(tiny.NewStdLogger(infoLogger, tiny.LevelInfo))
+	//client.SetLogger(tiny.NewStdLogger(errorLogger, tiny.LevelError))
 
 	request := client.NewRequest().SetBody(desiredData).SetURL(url).SetMethod(tiny.Post)
 	request.AddHeaders(map[string]string{"Test-Header": "this is a test"})
@@ -423,7 +423,7 @@ func TestGetQueryParams(t *testing.T) {
 
 	client := tiny.NewClient().SetTimeout(30)
 	infoLogger := log.New(os.Stderr, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	client.InfoLogger = infoLogger
+	client.SetLogger(tiny.NewStdLogger(infoLogger, tiny.LevelInfo))
 
 	request := client.NewRequest().SetURL(url).SetMethod("GET").
 		AddHeaders(map[string]string{"Test-Header": "this is a test"}).
@@ -505,3 +505,40 @@ func TestPostCancelled(t *testing.T) {
 
 	require.Error(t, err)
 }
+
+func TestMultiValueHeaders(t *testing.T) {
+
+	// Start a local HTTP server
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			require.Equal(t, []string{"application/json", "text/plain"}, req.Header["Accept"])
+			require.Equal(t, []string{"<https://example.com/1>; rel=next", "<https://example.com/0>; rel=prev"}, req.Header["Link"])
+			require.Equal(t, []string{"replaced"}, req.Header["X-Single"])
+			require.Equal(t, "application/json; charset=utf-8", req.Header.Get("Content-Type"))
+
+			b, _ := ioutil.ReadAll(req.Body)
+			require.Equal(t, `{"data":"done!"}`, string(b))
+		}),
+	)
+	defer server.Close()
+
+	client := tiny.NewClient().SetHeader("Accept", "application/xml")
+
+	// Lower case content type is still found when the body is marshalled
+	request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Post).
+		SetBody(map[string]string{"data": "done!"}).
+		SetHeader("content-type", "application/json; charset=utf-8").
+		AddHeader("accept", "application/json").
+		AddHeader("Accept", "text/plain").
+		AddHeader("Link", "<https://example.com/1>; rel=next").
+		AddHeader("Link", "<https://example.com/0>; rel=prev").
+		AddHeader("X-Single", "first").
+		SetHeader("x-single", "replaced")
+
+	require.Equal(t, "application/json", request.Headers.Get("ACCEPT"))
+
+	response, err := client.Send(request)
+
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+}
@@ -0,0 +1,116 @@
+package interview_accountapi_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescing(t *testing.T) {
+
+	var calls int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		rw.Header().Set("X-Token", req.Header.Get("Authorization"))
+		rw.Write([]byte("shared body"))
+	}))
+	defer server.Close()
+
+	client := tiny.NewClient().SetCoalescing("Accept")
+
+	var wg sync.WaitGroup
+	var coalesced int32
+	send := func(token string) {
+		defer wg.Done()
+		request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get)
+		if token != "" {
+			request.SetAuthenticator(tiny.NewBearerToken(token))
+		}
+		response, err := client.Send(request)
+		require.NoError(t, err)
+		body, err := response.ReadBody()
+		require.NoError(t, err)
+		require.Equal(t, "shared body", string(body))
+		if response.Coalesced {
+			atomic.AddInt32(&coalesced, 1)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go send("")
+	}
+	// Another caller's credentials are never shared
+	wg.Add(1)
+	go send("other")
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	require.Equal(t, int32(9), atomic.LoadInt32(&coalesced))
+
+	// Requests after the call finished are sent again
+	wg.Add(1)
+	send("")
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestCoalescingLeaderCancelled(t *testing.T) {
+
+	var calls int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// The leading call never answers before its caller gives up
+			select {
+			case <-req.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		rw.Write([]byte("body"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := tiny.NewClient().SetLogger(tiny.NopLogger).SetCoalescing()
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		results, _ := client.SendAll(ctx, []*tiny.Request{client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get)}, tiny.BatchOptions{})
+		leader <- results[0].Err
+	}()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, 5*time.Millisecond)
+
+	waiter := make(chan *tiny.Response, 1)
+	go func() {
+		response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+		require.NoError(t, err)
+		waiter <- response
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	require.True(t, errors.Is(<-leader, context.Canceled))
+	// The waiter's own context is live, so it sends the request itself instead of failing with the leader's error
+	select {
+	case response := <-waiter:
+		body, err := response.ReadBody()
+		require.NoError(t, err)
+		require.Equal(t, "body", string(body))
+		require.False(t, response.Coalesced)
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter didn't get a response")
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,178 @@
+package interview_accountapi_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newVersionedServer keeps one resource with a version, stale versions and etags are rejected
+func newVersionedServer(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	version := 0
+	modified := time.Date(2021, 6, 7, 10, 0, 0, 0, time.UTC)
+
+	return httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			etag := fmt.Sprintf(`"v%d"`, version)
+			rw.Header().Set("ETag", etag)
+			switch req.Method {
+			case "GET":
+				fmt.Fprintf(rw, `{"data":{"version":%d}}`, version)
+				return
+			case "DELETE":
+				if req.URL.Query().Get("version") != fmt.Sprint(version) {
+					rw.WriteHeader(http.StatusConflict)
+					return
+				}
+			case "PATCH":
+				body := struct {
+					Data struct {
+						Version *int `json:"version"`
+					} `json:"data"`
+				}{}
+				b, _ := ioutil.ReadAll(req.Body)
+				require.NoError(t, json.Unmarshal(b, &body))
+				if body.Data.Version == nil || *body.Data.Version != version {
+					rw.WriteHeader(http.StatusConflict)
+					return
+				}
+			case "PUT":
+				if match := req.Header.Get("If-Match"); match != "" && match != etag {
+					rw.WriteHeader(http.StatusPreconditionFailed)
+					return
+				}
+				if since := req.Header.Get("If-Unmodified-Since"); since != "" {
+					unmodifiedSince, err := http.ParseTime(since)
+					require.NoError(t, err)
+					if modified.After(unmodifiedSince) {
+						rw.WriteHeader(http.StatusPreconditionFailed)
+						return
+					}
+				}
+			}
+			version++
+			modified = modified.Add(time.Hour)
+			rw.Header().Set("ETag", fmt.Sprintf(`"v%d"`, version))
+		}),
+	)
+}
+
+func TestIfMatchReadModifyWrite(t *testing.T) {
+
+	server := newVersionedServer(t)
+	defer server.Close()
+
+	client := tiny.NewClient()
+
+	response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	etag := response.ETag()
+	require.Equal(t, `"v0"`, etag)
+
+	// Another writer updates the resource in between
+	_, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Put))
+	require.NoError(t, err)
+
+	response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Put).IfMatch(etag))
+	require.True(t, errors.Is(err, tiny.ErrPreconditionFailed))
+	require.Equal(t, http.StatusPreconditionFailed, response.Response.StatusCode)
+
+	// Read again and retry with the new etag
+	response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Put).IfMatch(response.ETag()))
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+
+	response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Put).
+		IfUnmodifiedSince(time.Date(2021, 6, 7, 10, 0, 0, 0, time.UTC)))
+	require.True(t, errors.Is(err, tiny.ErrPreconditionFailed))
+}
+
+func TestVersionStrategies(t *testing.T) {
+
+	server := newVersionedServer(t)
+	defer server.Close()
+
+	client := tiny.NewClient()
+
+	// Default strategy sends the version as query param
+	_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Delete).IfVersion(0))
+	require.NoError(t, err)
+
+	response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Delete).IfVersion(0))
+	require.True(t, errors.Is(err, tiny.ErrConflict))
+	require.Equal(t, http.StatusConflict, response.Response.StatusCode)
+
+	// Version inside a JSON body, existing fields are kept
+	client.SetVersionStrategy(tiny.VersionInBody{"data", "version"})
+	body := map[string]interface{}{"data": map[string]interface{}{"id": "ad27e265"}}
+	request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Patch).
+		SetContentType(tiny.JsonContentType).
+		SetBody(body).
+		IfVersion(1)
+	_, err = client.Send(request)
+	require.NoError(t, err)
+
+	// Requests without a precondition keep returning 409 as a response only
+	response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Delete).AddQueryParam("version", "0"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, response.Response.StatusCode)
+}
+
+func TestPreconditionErrorRedactsURL(t *testing.T) {
+
+	server := newVersionedServer(t)
+	defer server.Close()
+
+	client := tiny.NewClient().SetLogger(tiny.NopLogger).
+		SetAuthenticator(tiny.NewAPIKey("api_key", "SUPERSECRET", tiny.APIKeyInQuery))
+	_, err := client.Send(client.NewRequest().SetURL(server.URL + "/acc").SetMethod(tiny.Delete).IfVersion(0))
+	require.NoError(t, err)
+
+	// 409 for the stale version, the api key in the query isn't part of the error
+	_, err = client.Send(client.NewRequest().SetURL(server.URL + "/acc").SetMethod(tiny.Delete).IfVersion(0))
+	require.True(t, errors.Is(err, tiny.ErrConflict))
+	require.NotContains(t, err.Error(), "SUPERSECRET")
+	require.Contains(t, err.Error(), "api_key=[REDACTED]")
+
+	// 412 for the stale etag
+	_, err = client.Send(client.NewRequest().SetURL(server.URL + "/acc").SetMethod(tiny.Put).IfMatch(`"v0"`))
+	require.True(t, errors.Is(err, tiny.ErrPreconditionFailed))
+	require.NotContains(t, err.Error(), "SUPERSECRET")
+}
+
+func TestVersionInBodyRejectsOtherBodies(t *testing.T) {
+
+	server := newVersionedServer(t)
+	defer server.Close()
+
+	client := tiny.NewClient().SetLogger(tiny.NopLogger).SetVersionStrategy(tiny.VersionInBody{"data", "version"})
+	for _, body := range []string{"", "plain text", `["data"]`, `null`, `{"data":{}} {}`} {
+		request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Patch).IfVersion(0)
+		if body != "" {
+			request.SetBody([]byte(body))
+		}
+		_, err := client.Send(request)
+		require.Error(t, err, body)
+		require.Contains(t, err.Error(), "version can't be set into the body", body)
+		// The body of the caller is left as it is
+		if body != "" {
+			b, err := request.ReadBody()
+			require.NoError(t, err)
+			require.Equal(t, body, string(b))
+		}
+	}
+}
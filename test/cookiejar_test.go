@@ -0,0 +1,165 @@
+package interview_accountapi_test
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieJarSession(t *testing.T) {
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/login":
+				http.SetCookie(rw, &http.Cookie{Name: "session", Value: "abc", Path: "/", MaxAge: 3600})
+				http.SetCookie(rw, &http.Cookie{Name: "admin", Value: "yes", Path: "/admin"})
+				http.SetCookie(rw, &http.Cookie{Name: "secure", Value: "https-only", Path: "/", Secure: true})
+			case "/accounts":
+				cookie, err := req.Cookie("session")
+				require.NoError(t, err)
+				require.Equal(t, "abc", cookie.Value)
+				_, err = req.Cookie("admin")
+				require.Error(t, err)
+				_, err = req.Cookie("secure")
+				require.Error(t, err)
+			case "/admin/users":
+				require.Equal(t, "admin=yes; session=abc", req.Header.Get("Cookie"))
+			case "/logout":
+				http.SetCookie(rw, &http.Cookie{Name: "session", Value: "", Path: "/", MaxAge: -1})
+			}
+		}),
+	)
+	defer server.Close()
+
+	jar := tiny.NewCookieJar()
+	client := tiny.NewClient().SetCookieJar(jar)
+
+	for _, path := range []string{"/login", "/accounts", "/admin/users", "/logout"} {
+		response, err := client.Send(client.NewRequest().SetURL(server.URL + path).SetMethod(tiny.Get))
+		require.NoError(t, err)
+		require.Equal(t, 200, response.Response.StatusCode, path)
+	}
+
+	serverURL, _ := url.Parse(server.URL)
+	cookies := jar.DomainCookies(serverURL.Hostname())
+	require.Len(t, cookies, 2)
+	require.Equal(t, "admin", cookies[0].Name)
+	require.Equal(t, "/admin", cookies[0].Path)
+	require.Equal(t, "secure", cookies[1].Name)
+
+	jar.Clear(serverURL.Hostname())
+	require.Empty(t, jar.Domains())
+}
+
+func TestCookieJarDomainScope(t *testing.T) {
+
+	jar := tiny.NewCookieJar()
+	origin, _ := url.Parse("http://api.example.com/v1/accounts")
+	jar.SetCookies(origin, []*http.Cookie{
+		{Name: "shared", Value: "1", Domain: ".example.com"},
+		{Name: "host", Value: "2"},
+		{Name: "other", Value: "3", Domain: "other.com"},
+		{Name: "tld", Value: "4", Domain: "com"},
+	})
+
+	require.Equal(t, []string{"api.example.com", "example.com"}, jar.Domains())
+
+	sibling, _ := url.Parse("http://www.example.com/v1/accounts")
+	cookies := jar.Cookies(sibling)
+	require.Len(t, cookies, 1)
+	require.Equal(t, "shared", cookies[0].Name)
+
+	// Default path is the directory of the request path
+	require.Len(t, jar.Cookies(origin), 2)
+	outside, _ := url.Parse("http://api.example.com/v2")
+	require.Empty(t, jar.Cookies(outside))
+}
+
+// suffixList is a public suffix list of a few suffixes
+type suffixList []string
+
+func (list suffixList) PublicSuffix(domain string) string {
+	for _, suffix := range list {
+		if domain == suffix || strings.HasSuffix(domain, "."+suffix) {
+			return suffix
+		}
+	}
+	return domain[strings.LastIndex(domain, ".")+1:]
+}
+
+func (list suffixList) String() string {
+	return "test"
+}
+
+func TestCookieJarPublicSuffix(t *testing.T) {
+
+	jar := tiny.NewCookieJar().SetPublicSuffixList(suffixList{"co.uk"})
+	origin, _ := url.Parse("http://a.co.uk/")
+	jar.SetCookies(origin, []*http.Cookie{
+		{Name: "super", Value: "1", Domain: "co.uk"},
+		{Name: "site", Value: "2", Domain: "a.co.uk"},
+	})
+
+	// The supercookie isn't sent to other sites under co.uk
+	other, _ := url.Parse("http://b.co.uk/")
+	require.Empty(t, jar.Cookies(other))
+	sub, _ := url.Parse("http://www.a.co.uk/")
+	cookies := jar.Cookies(sub)
+	require.Len(t, cookies, 1)
+	require.Equal(t, "site", cookies[0].Name)
+
+	// A public suffix host may set a cookie for itself, it stays host only
+	suffixHost, _ := url.Parse("http://co.uk/")
+	jar.SetCookies(suffixHost, []*http.Cookie{{Name: "own", Value: "3", Domain: "co.uk"}})
+	require.Len(t, jar.Cookies(suffixHost), 1)
+	require.Empty(t, jar.Cookies(other))
+}
+
+func TestCookieJarPersistence(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "cookies")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	origin, _ := url.Parse("https://api.example.com/")
+	jar := tiny.NewCookieJar()
+	jar.SetCookies(origin, []*http.Cookie{
+		{Name: "session", Value: "abc", Expires: time.Now().Add(time.Hour), HttpOnly: true, Secure: true},
+		{Name: "shared", Value: "1", Domain: "example.com", Path: "/v1"},
+		{Name: "expired", Value: "x", Expires: time.Now().Add(-time.Hour)},
+	})
+
+	for _, format := range []tiny.CookieFileFormat{tiny.CookieFormatJSON, tiny.CookieFormatNetscape} {
+		filename := filepath.Join(dir, "cookies."+string(format))
+		require.NoError(t, jar.Save(filename, format))
+
+		loaded := tiny.NewCookieJar()
+		require.NoError(t, loaded.Load(filename, format))
+
+		require.Equal(t, jar.Domains(), loaded.Domains(), format)
+		session := loaded.DomainCookies("api.example.com")
+		require.Len(t, session, 1, format)
+		require.Equal(t, "abc", session[0].Value)
+		require.True(t, session[0].HttpOnly)
+		require.True(t, session[0].Secure)
+
+		v1, _ := url.Parse("https://www.example.com/v1/accounts")
+		require.Len(t, loaded.Cookies(v1), 1, format)
+	}
+
+	var buffer bytes.Buffer
+	require.NoError(t, jar.Export(&buffer, tiny.CookieFormatNetscape))
+	require.True(t, strings.HasPrefix(buffer.String(), "# Netscape HTTP Cookie File\n"))
+	require.Contains(t, buffer.String(), "#HttpOnly_api.example.com\tFALSE\t/\tTRUE\t")
+	require.Contains(t, buffer.String(), ".example.com\tTRUE\t/v1\tFALSE\t0\tshared\t1")
+}
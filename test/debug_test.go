@@ -0,0 +1,92 @@
+package interview_accountapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newDebugServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/binary" {
+			rw.Header().Set(tiny.ContentType, "application/octet-stream")
+			rw.Write([]byte{0x00, 0x01, 0xfe, 0xff})
+			return
+		}
+		rw.Header().Set(tiny.ContentType, tiny.JsonContentType)
+		rw.Write([]byte(`{"id":"1","version":0}`))
+	}))
+}
+
+func debugOutput(t *testing.T, formatter tiny.DebugFormatter, path string) []string {
+	server := newDebugServer()
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := tiny.NewClient().SetLogger(logger).SetDebugMode(true).SetDebugFormatter(formatter)
+	request := client.NewRequest().SetURL(server.URL+path).SetMethod(tiny.Post).
+		SetContentType(tiny.JsonContentType).
+		SetBody(map[string]string{"name": "Samantha"}).
+		AddQueryParam("page", "1")
+	_, err := client.Send(request)
+	require.NoError(t, err)
+
+	var output []string
+	for _, entry := range logger.levelEntries(tiny.LevelInfo) {
+		output = append(output, entry.msg)
+	}
+	return output
+}
+
+func TestDebugWireFormat(t *testing.T) {
+
+	output := debugOutput(t, tiny.WireFormatter{}, "/accounts")
+	require.Len(t, output, 2)
+	require.True(t, strings.HasPrefix(output[0], "\nPOST /accounts?page=1 HTTP/1.1\r\n"), output[0])
+	require.Contains(t, output[0], "Content-Type: application/json; charset=utf-8\r\n")
+	require.Contains(t, output[0], "\r\n\r\n{\"name\":\"Samantha\"}")
+	require.True(t, strings.HasPrefix(output[1], "\nHTTP/1.1 200 OK\r\n"), output[1])
+	require.True(t, strings.HasSuffix(output[1], "\r\n\r\n{\"id\":\"1\",\"version\":0}"), output[1])
+}
+
+func TestDebugPrettyAndColorFormat(t *testing.T) {
+
+	output := debugOutput(t, tiny.BannerFormatter{Pretty: true}, "/accounts")
+	require.Contains(t, output[0], "~~~ HTTP REQUEST ~~~")
+	require.Contains(t, output[1], "{\n  \"id\": \"1\",\n  \"version\": 0\n}")
+
+	output = debugOutput(t, tiny.ColorFormatter{}, "/accounts")
+	require.Contains(t, output[1], "\033[32m")
+	require.Contains(t, output[1], "\"version\": 0")
+}
+
+func TestDebugJSONFormat(t *testing.T) {
+
+	// One single line record per exchange, written as is without the logger
+	var buffer bytes.Buffer
+	require.Empty(t, debugOutput(t, tiny.JSONFormatter{Writer: &buffer}, "/accounts"))
+	output := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	require.Len(t, output, 1)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(output[0]), &record))
+	require.Equal(t, "POST", record["method"])
+	require.Equal(t, float64(200), record["status"])
+	require.Equal(t, map[string]interface{}{"name": "Samantha"}, record["request_body"])
+	require.Equal(t, map[string]interface{}{"id": "1", "version": float64(0)}, record["response_body"])
+}
+
+func TestDebugHexDump(t *testing.T) {
+
+	output := debugOutput(t, tiny.BannerFormatter{}, "/binary")
+	require.Contains(t, output[1], "00000000  00 01 fe ff")
+
+	var buffer bytes.Buffer
+	debugOutput(t, tiny.JSONFormatter{Writer: &buffer}, "/binary")
+	require.Contains(t, buffer.String(), `"response_body":"AAH+/w=="`)
+}
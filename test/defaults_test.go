@@ -0,0 +1,92 @@
+package interview_accountapi_test
+
+import (
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClientDefaults(t *testing.T) {
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			query := req.URL.Query()
+			switch req.URL.Path {
+			case "/inherit":
+				require.Equal(t, "application/json", req.Header.Get("Accept"))
+				require.Equal(t, "billing", req.Header.Get("X-Request-Source"))
+				require.Equal(t, "host-tenant", req.Header.Get("X-Tenant"))
+				require.Equal(t, "client", query.Get("source"))
+				require.Equal(t, "eu", query.Get("region"))
+			case "/override":
+				require.Equal(t, "text/plain", req.Header.Get("Accept"))
+				require.Equal(t, "request-tenant", req.Header.Get("X-Tenant"))
+				require.Equal(t, "request", query.Get("source"))
+				require.Equal(t, "eu", query.Get("region"))
+			case "/remove":
+				_, ok := req.Header["X-Request-Source"]
+				require.False(t, ok)
+				_, ok = req.Header["X-Tenant"]
+				require.False(t, ok)
+				_, ok = query["region"]
+				require.False(t, ok)
+				require.Equal(t, "client", query.Get("source"))
+			case "/embedded":
+				// Query params embedded in the URL aren't overridden or duplicated by defaults
+				require.Equal(t, []string{"url"}, query["source"])
+				require.Equal(t, []string{"2"}, query["page"])
+				require.Equal(t, "eu", query.Get("region"))
+			}
+			// Request body is marshalled as JSON because of the inherited Content-Type
+			if req.Method == "POST" {
+				require.Equal(t, "application/json; charset=utf-8", req.Header.Get("Content-Type"))
+				b, _ := ioutil.ReadAll(req.Body)
+				require.Equal(t, `{"data":"done!"}`, string(b))
+			}
+		}),
+	)
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := tiny.NewClient().
+		SetHeader("Accept", "application/json").
+		SetHeader("X-Request-Source", "billing").
+		SetHeader("X-Tenant", "client-tenant").
+		SetQueryParam("source", "client").
+		SetHostHeader(serverURL.Hostname(), "X-Tenant", "hostname-tenant").
+		SetHostHeader(serverURL.Host, "X-Tenant", "host-tenant").
+		SetHostHeader(serverURL.Host, tiny.ContentType, tiny.JsonContentType).
+		SetHostQueryParam(serverURL.Host, "region", "eu").
+		SetHostHeader("other.example.com", "X-Tenant", "other")
+
+	request := client.NewRequest().SetURL(server.URL + "/inherit").SetMethod(tiny.Post).
+		SetBody(map[string]string{"data": "done!"})
+	response, err := client.Send(request)
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+
+	request = client.NewRequest().SetURL(server.URL+"/override").SetMethod(tiny.Get).
+		AddHeader("Accept", "text/plain").
+		AddHeader("X-Tenant", "request-tenant").
+		AddQueryParam("source", "request")
+	response, err = client.Send(request)
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+
+	request = client.NewRequest().SetURL(server.URL + "/remove").SetMethod(tiny.Get).
+		RemoveHeader("x-request-source").
+		RemoveHeader("X-Tenant").
+		RemoveQueryParam("region")
+	response, err = client.Send(request)
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+
+	request = client.NewRequest().SetURL(server.URL + "/embedded?source=url&page=2").SetMethod(tiny.Get)
+	response, err = client.Send(request)
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+}
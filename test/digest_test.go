@@ -0,0 +1,204 @@
+package interview_accountapi_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+var digestParam = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^,\s]*))`)
+
+// newDigestServer verifies Digest credentials like an RFC 7616 server and records the nonce counts it receives
+func newDigestServer(t *testing.T, algorithm, qop string, nonceCounts *[]string) *httptest.Server {
+	newHash := md5.New
+	if algorithm == "SHA-256" {
+		newHash = sha256.New
+	}
+	h := func(s string) string {
+		var hasher hash.Hash = newHash()
+		hasher.Write([]byte(s))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	return httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			authorization := req.Header.Get("Authorization")
+			if authorization == "" {
+				rw.Header().Add("WWW-Authenticate", `Digest realm="test", qop="`+qop+`", algorithm=MD5, nonce="nonce-md5", opaque="opaque"`)
+				if algorithm == "SHA-256" {
+					rw.Header().Add("WWW-Authenticate", `Digest realm="test", qop="`+qop+`", algorithm=SHA-256, nonce="nonce-sha", opaque="opaque"`)
+				}
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			params := map[string]string{}
+			for _, match := range digestParam.FindAllStringSubmatch(authorization, -1) {
+				params[match[1]] = match[2] + match[3]
+			}
+			require.Equal(t, algorithm, params["algorithm"])
+			require.Equal(t, "opaque", params["opaque"])
+			require.Equal(t, req.URL.RequestURI(), params["uri"])
+
+			body, _ := ioutil.ReadAll(req.Body)
+			ha1 := h("user:test:pass")
+			ha2 := h(req.Method + ":" + params["uri"])
+			if params["qop"] == "auth-int" {
+				ha2 = h(req.Method + ":" + params["uri"] + ":" + h(string(body)))
+			}
+			expected := h(ha1 + ":" + params["nonce"] + ":" + params["nc"] + ":" + params["cnonce"] + ":" + params["qop"] + ":" + ha2)
+			if expected != params["response"] {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			*nonceCounts = append(*nonceCounts, params["nc"])
+			rw.Write(body)
+		}),
+	)
+}
+
+func TestDigestAuth(t *testing.T) {
+
+	var table = []struct {
+		algorithm string
+		qop       string
+		usedQop   string
+	}{
+		{"MD5", "auth", "auth"},
+		{"SHA-256", "auth,auth-int", "auth"},
+		{"SHA-256", "auth-int", "auth-int"},
+	}
+
+	for _, row := range table {
+		var nonceCounts []string
+		server := newDigestServer(t, row.algorithm, row.qop, &nonceCounts)
+
+		client := tiny.NewClient().SetAuthenticator(tiny.NewDigestAuth("user", "pass"))
+		for i := 0; i < 3; i++ {
+			request := client.NewRequest().SetURL(fmt.Sprintf("%s/digest", server.URL)).SetMethod(tiny.Post).
+				AddQueryParam("attempt", fmt.Sprint(i)).
+				SetBody(desiredData)
+
+			response, err := client.Send(request)
+			require.NoError(t, err)
+			require.Equal(t, 200, response.Response.StatusCode, row)
+			require.Contains(t, response.Request.HttpRequest.Header.Get("Authorization"), "qop="+row.usedQop)
+
+			body, err := response.ReadBody()
+			require.NoError(t, err)
+			require.Equal(t, desiredData, string(body))
+		}
+		server.Close()
+
+		// Nonce count continues across requests of the same client
+		require.Equal(t, []string{"00000001", "00000002", "00000003"}, nonceCounts, row)
+	}
+}
+
+func TestDigestAuthWrongPassword(t *testing.T) {
+
+	var nonceCounts []string
+	server := newDigestServer(t, "MD5", "auth", &nonceCounts)
+	defer server.Close()
+
+	client := tiny.NewClient().SetAuthenticator(tiny.NewDigestAuth("user", "wrong"))
+	request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get)
+
+	response, err := client.Send(request)
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, response.Response.StatusCode)
+	require.Empty(t, nonceCounts)
+}
+
+func TestDigestAuthConcurrentFirstRequests(t *testing.T) {
+
+	// Both first requests arrive before either challenge is answered and get the same nonce
+	var mu sync.Mutex
+	var waiting int
+	bothArrived := make(chan struct{})
+	var nonceCounts []string
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			authorization := req.Header.Get("Authorization")
+			if authorization == "" {
+				mu.Lock()
+				waiting++
+				if waiting == 2 {
+					close(bothArrived)
+				}
+				mu.Unlock()
+				<-bothArrived
+				rw.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth", nonce="shared"`)
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			params := map[string]string{}
+			for _, match := range digestParam.FindAllStringSubmatch(authorization, -1) {
+				params[match[1]] = match[2] + match[3]
+			}
+			require.Equal(t, "shared", params["nonce"])
+			mu.Lock()
+			nonceCounts = append(nonceCounts, params["nc"])
+			mu.Unlock()
+		}),
+	)
+	defer server.Close()
+
+	client := tiny.NewClient().SetAuthenticator(tiny.NewDigestAuth("user", "pass"))
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, response.Response.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	// The retries share the nonce with their own nonce counts
+	require.ElementsMatch(t, []string{"00000001", "00000002"}, nonceCounts)
+}
+
+func TestDigestAuthPerHost(t *testing.T) {
+
+	var firstAuthorizations []string
+	newServer := func(nonce string) *httptest.Server {
+		return httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				authorization := req.Header.Get("Authorization")
+				if !regexp.MustCompile(`nonce="` + nonce + `"`).MatchString(authorization) {
+					firstAuthorizations = append(firstAuthorizations, authorization)
+					rw.Header().Set("WWW-Authenticate", `Digest realm="`+nonce+`", qop="auth", nonce="`+nonce+`"`)
+					rw.WriteHeader(http.StatusUnauthorized)
+				}
+			}),
+		)
+	}
+	first := newServer("first")
+	defer first.Close()
+	second := newServer("second")
+	defer second.Close()
+
+	client := tiny.NewClient().SetAuthenticator(tiny.NewDigestAuth("user", "pass"))
+	for _, server := range []*httptest.Server{first, second, first, second} {
+		response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.Response.StatusCode)
+	}
+
+	// Each host challenged once and never got the nonce of the other host
+	require.Equal(t, []string{"", ""}, firstAuthorizations)
+}
@@ -0,0 +1,86 @@
+package interview_accountapi_test
+
+import (
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgedRequest(t *testing.T) {
+
+	var calls int32
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// The original request is slow and gets cancelled when the hedge wins
+			<-req.Context().Done()
+			close(cancelled)
+			return
+		}
+		rw.Write([]byte("hedged"))
+	}))
+	defer server.Close()
+
+	client := tiny.NewClient().SetHedgePolicy(tiny.NewHedgePolicy(20 * time.Millisecond))
+
+	response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	body, err := response.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, "hedged", string(body))
+	require.Equal(t, 2, response.Attempt)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("losing attempt was not cancelled")
+	}
+}
+
+func TestHedgedRequestFastResponse(t *testing.T) {
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client := tiny.NewClient().SetHedgePolicy(tiny.NewHedgePolicy(20 * time.Millisecond))
+
+	response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	require.Equal(t, 1, response.Attempt)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Head))
+	require.NoError(t, err)
+	require.Equal(t, 1, response.Attempt)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Writes aren't hedged by default, even idempotent ones
+	for _, method := range []tiny.Method{tiny.Post, tiny.Put, tiny.Delete} {
+		response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(method))
+		require.NoError(t, err)
+		require.Equal(t, 0, response.Attempt, method)
+	}
+	require.Equal(t, int32(5), atomic.LoadInt32(&calls))
+
+	// A write which opts in is hedged
+	response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Put).SetHedging(true))
+	require.NoError(t, err)
+	require.NotEqual(t, 0, response.Attempt)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) == 7
+	}, time.Second, 5*time.Millisecond)
+
+	// A GET which opts out isn't hedged
+	response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get).SetHedging(false))
+	require.NoError(t, err)
+	require.Equal(t, 0, response.Attempt)
+}
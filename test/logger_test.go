@@ -0,0 +1,105 @@
+package interview_accountapi_test
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type logEntry struct {
+	level  tiny.Level
+	msg    string
+	fields map[string]interface{}
+}
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (logger *recordingLogger) Log(level tiny.Level, msg string, fields ...tiny.Field) {
+	entry := logEntry{level: level, msg: msg, fields: map[string]interface{}{}}
+	for _, field := range fields {
+		entry.fields[field.Key] = field.Value
+	}
+	logger.mu.Lock()
+	logger.entries = append(logger.entries, entry)
+	logger.mu.Unlock()
+}
+
+func (logger *recordingLogger) levelEntries(level tiny.Level) []logEntry {
+	var entries []logEntry
+	for _, entry := range logger.entries {
+		if entry.level == level {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestLoggerFields(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := tiny.NewClient().SetLogger(logger)
+
+	_, err := client.Send(client.NewRequest().SetURL(server.URL + "/accounts").SetMethod(tiny.Post))
+	require.NoError(t, err)
+
+	completed := logger.levelEntries(tiny.LevelDebug)
+	require.Len(t, completed, 1)
+	require.Equal(t, "request completed", completed[0].msg)
+	require.Equal(t, tiny.Post, completed[0].fields["method"])
+	require.Equal(t, server.URL+"/accounts", completed[0].fields["url"])
+	require.Equal(t, http.StatusCreated, completed[0].fields["status"])
+	require.Contains(t, completed[0].fields, "duration")
+	require.Empty(t, logger.levelEntries(tiny.LevelError))
+}
+
+func TestLoggerLogsErrorOnce(t *testing.T) {
+
+	closed := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	closed.Close()
+
+	logger := &recordingLogger{}
+	client := tiny.NewClient().SetLogger(logger)
+
+	_, err := client.Send(client.NewRequest().SetURL(closed.URL).SetMethod(tiny.Get))
+	require.Error(t, err)
+
+	errors := logger.levelEntries(tiny.LevelError)
+	require.Len(t, errors, 1)
+	require.Equal(t, "request failed", errors[0].msg)
+	require.Equal(t, err, errors[0].fields["error"])
+	require.Equal(t, tiny.Get, errors[0].fields["method"])
+}
+
+func TestStdLogger(t *testing.T) {
+
+	var buffer bytes.Buffer
+	logger := tiny.NewStdLogger(log.New(&buffer, "", 0), tiny.LevelInfo)
+
+	logger.Log(tiny.LevelDebug, "dropped")
+	logger.Log(tiny.LevelWarn, "kept", tiny.Field{Key: "status", Value: 503}, tiny.Field{Key: "error", Value: "connection reset"})
+	require.Equal(t, "WARN kept status=503 error=\"connection reset\"\n", buffer.String())
+
+	// NopLogger is silent even for failed requests
+	client := tiny.NewClient().SetLogger(tiny.NopLogger)
+	_, err := client.Send(client.NewRequest().SetURL("%zz").SetMethod(tiny.Get))
+	require.Error(t, err)
+
+	// A nil logger disables logging too
+	client = tiny.NewClient().SetLogger(nil)
+	require.Equal(t, tiny.NopLogger, client.Logger)
+	_, err = client.Send(client.NewRequest().SetURL("%zz").SetMethod(tiny.Get))
+	require.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package interview_accountapi_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"testing"
+)
+
+// reportRecorder records what the mock transport reports instead of failing the test
+type reportRecorder struct {
+	errors   []string
+	messages []string
+}
+
+func (recorder *reportRecorder) Helper() {}
+
+func (recorder *reportRecorder) Errorf(format string, args ...interface{}) {
+	recorder.errors = append(recorder.errors, fmt.Sprintf(format, args...))
+}
+
+func (recorder *reportRecorder) Fatal(args ...interface{}) {
+	recorder.messages = append(recorder.messages, fmt.Sprint(args...))
+}
+
+func TestMockTransport(t *testing.T) {
+
+	mock := tiny.NewMockTransport(t)
+	defer mock.AssertExpectations()
+	create := mock.Expect(tiny.Post, "/v1/accounts").
+		WithHeader("X-Tenant", "acme").
+		WithJSONBody(`{"id": "1", "tags": ["a", "b"]}`).
+		RespondHeader("Location", "/v1/accounts/1").
+		Respond(http.StatusCreated, map[string]string{"id": "1"})
+	list := mock.Expect(tiny.Get, "/v1/accounts").WithQuery("page", "2").Times(2).Respond(http.StatusOK, "[]")
+	mock.Expect(tiny.Delete, "/v1/accounts/1").RespondError(errors.New("connection reset"))
+	client := tiny.NewClient().SetTransport(mock)
+
+	response, err := client.Send(client.NewRequest().SetURL("api.test/v1/accounts").SetMethod(tiny.Post).
+		SetHeader("X-Tenant", "acme").SetContentType(tiny.JsonContentType).
+		SetBody(map[string]interface{}{"tags": []string{"a", "b"}, "id": "1"}))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, response.Response.StatusCode)
+	require.Equal(t, "/v1/accounts/1", response.Response.Header.Get("Location"))
+	var created map[string]string
+	require.NoError(t, response.BodyUnmarshall(&created))
+	require.Equal(t, "1", created["id"])
+	require.Equal(t, 1, create.Calls())
+
+	for i := 0; i < 2; i++ {
+		response, err = client.Send(client.NewRequest().SetURL("api.test/v1/accounts").SetMethod(tiny.Get).AddQueryParam("page", "2"))
+		require.NoError(t, err)
+		body, err := response.ReadBody()
+		require.NoError(t, err)
+		require.Equal(t, "[]", string(body))
+	}
+	require.Equal(t, 2, list.Calls())
+
+	_, err = client.Send(client.NewRequest().SetURL("api.test/v1/accounts/1").SetMethod(tiny.Delete))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "connection reset")
+}
+
+func TestMockTransportUnexpectedRequest(t *testing.T) {
+
+	recorder := &reportRecorder{}
+	mock := tiny.NewMockTransport(recorder)
+	mock.Expect(tiny.Get, "/v1/accounts").WithHeader("Accept", "application/json")
+	mock.Expect(tiny.Get, "/v1/users").Once()
+	client := tiny.NewClient().SetTransport(mock)
+
+	_, err := client.Send(client.NewRequest().SetURL("api.test/v1/accounts").SetMethod(tiny.Get).SetHeader("Accept", "text/plain"))
+	require.True(t, errors.Is(err, tiny.ErrUnexpectedRequest), err)
+	// Unexpected requests are reported as soon as they arrive
+	require.Len(t, recorder.errors, 1)
+	require.Contains(t, recorder.errors[0], "unexpected request GET http://api.test/v1/accounts")
+	require.Contains(t, recorder.errors[0], "Accept: text/plain")
+	require.Contains(t, recorder.errors[0], `GET /v1/accounts: header Accept: "application/json" not found in ["text/plain"]`)
+	require.Contains(t, recorder.errors[0], "GET /v1/users: path /v1/accounts != /v1/users")
+
+	_, err = client.Send(client.NewRequest().SetURL("api.test/v1/users").SetMethod(tiny.Get))
+	require.NoError(t, err)
+	// The second call exceeds Once
+	_, err = client.Send(client.NewRequest().SetURL("api.test/v1/users").SetMethod(tiny.Get))
+	require.True(t, errors.Is(err, tiny.ErrUnexpectedRequest), err)
+	require.Len(t, recorder.errors, 2)
+	require.Contains(t, recorder.errors[1], "GET /v1/users: already called 1 times")
+
+	mock.AssertExpectations()
+	require.Len(t, recorder.messages, 1)
+	report := recorder.messages[0]
+	require.Contains(t, report, "expectation GET /v1/accounts called 0 times, expected 1")
+	require.NotContains(t, report, "unexpected request")
+}
+
+func TestMockTransportInOrder(t *testing.T) {
+
+	recorder := &reportRecorder{}
+	mock := tiny.NewMockTransport(recorder).InOrder()
+	mock.Expect(tiny.Post, "/v1/accounts")
+	mock.Expect(tiny.Get, "/v1/accounts/1").AnyTimes()
+	mock.Expect(tiny.Delete, "/v1/accounts/1")
+	client := tiny.NewClient().SetTransport(mock)
+
+	_, err := client.Send(client.NewRequest().SetURL("api.test/v1/accounts/1").SetMethod(tiny.Delete))
+	require.True(t, errors.Is(err, tiny.ErrUnexpectedRequest), err)
+	_, err = client.Send(client.NewRequest().SetURL("api.test/v1/accounts").SetMethod(tiny.Post))
+	require.NoError(t, err)
+	// Expectations allowing no calls don't hold back the next ones
+	_, err = client.Send(client.NewRequest().SetURL("api.test/v1/accounts/1").SetMethod(tiny.Delete))
+	require.NoError(t, err)
+
+	require.Len(t, recorder.errors, 1)
+	require.Contains(t, recorder.errors[0], "request DELETE http://api.test/v1/accounts/1 out of order, expected POST /v1/accounts first")
+
+	mock.AssertExpectations()
+	require.Empty(t, recorder.messages)
+}
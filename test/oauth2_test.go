@@ -0,0 +1,165 @@
+package interview_accountapi_test
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTokenServer(t *testing.T, tokenRequests *int32, expiresIn int) *httptest.Server {
+	return httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			require.Equal(t, "POST", req.Method)
+			require.NoError(t, req.ParseForm())
+			require.Equal(t, "client_credentials", req.PostForm.Get("grant_type"))
+			require.Equal(t, "accounts:read accounts:write", req.PostForm.Get("scope"))
+
+			clientID, clientSecret, ok := req.BasicAuth()
+			require.True(t, ok)
+			require.Equal(t, "client-id", clientID)
+			require.Equal(t, "client-secret", clientSecret)
+
+			// Slow token endpoint so concurrent requests pile up waiting for it
+			time.Sleep(100 * time.Millisecond)
+			count := atomic.AddInt32(tokenRequests, 1)
+
+			rw.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(rw, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d}`, count, expiresIn)
+		}),
+	)
+}
+
+func TestClientCredentialsCaching(t *testing.T) {
+
+	var tokenRequests int32
+	tokenServer := newTokenServer(t, &tokenRequests, 3600)
+	defer tokenServer.Close()
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			require.Equal(t, "Bearer token-1", req.Header.Get("Authorization"))
+		}),
+	)
+	defer server.Close()
+
+	source := tiny.NewClientCredentials(tokenServer.URL, "client-id", "client-secret", "accounts:read", "accounts:write")
+	client := tiny.NewClient().SetAuthenticator(source)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get)
+			response, err := client.Send(request)
+			require.NoError(t, err)
+			require.Equal(t, 200, response.Response.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&tokenRequests))
+}
+
+func TestClientCredentialsExpiry(t *testing.T) {
+
+	var tokenRequests int32
+	// expires_in is shorter than the expiry delta so every request needs a new token
+	tokenServer := newTokenServer(t, &tokenRequests, 5)
+	defer tokenServer.Close()
+
+	source := tiny.NewClientCredentials(tokenServer.URL, "client-id", "client-secret", "accounts:read", "accounts:write")
+
+	token, err := source.Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+
+	token, err = source.Token()
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token)
+}
+
+func TestClientCredentialsRetryOnUnauthorized(t *testing.T) {
+
+	var tokenRequests int32
+	tokenServer := newTokenServer(t, &tokenRequests, 3600)
+	defer tokenServer.Close()
+
+	var apiRequests int32
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&apiRequests, 1)
+			// Body must be sent again on the retry
+			b, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			require.Equal(t, desiredData, string(b))
+
+			// The first token was revoked by the server
+			if req.Header.Get("Authorization") == "Bearer token-1" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			require.Equal(t, "Bearer token-2", req.Header.Get("Authorization"))
+		}),
+	)
+	defer server.Close()
+
+	source := tiny.NewClientCredentials(tokenServer.URL, "client-id", "client-secret", "accounts:read", "accounts:write")
+	client := tiny.NewClient().SetAuthenticator(source)
+
+	request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Post).SetBody(desiredData)
+	response, err := client.Send(request)
+
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+	require.Equal(t, int32(2), atomic.LoadInt32(&apiRequests))
+	require.Equal(t, int32(2), atomic.LoadInt32(&tokenRequests))
+}
+
+func TestClientCredentialsTokenError(t *testing.T) {
+
+	tokenServer := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(`{"error":"invalid_client"}`))
+		}),
+	)
+	defer tokenServer.Close()
+
+	source := tiny.NewClientCredentials(tokenServer.URL, "client-id", "wrong-secret")
+	client := tiny.NewClient().SetAuthenticator(source)
+
+	request := client.NewRequest().SetURL(tokenServer.URL).SetMethod(tiny.Get)
+	_, err := client.Send(request)
+
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid_client")
+}
+
+func TestClientCredentialsNonJSONError(t *testing.T) {
+
+	tokenServer := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Content-Type", "text/html")
+			rw.WriteHeader(http.StatusBadGateway)
+			rw.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+		}),
+	)
+	defer tokenServer.Close()
+
+	source := tiny.NewClientCredentials(tokenServer.URL, "client-id", "client-secret")
+	client := tiny.NewClient().SetAuthenticator(source)
+
+	_, err := client.Send(client.NewRequest().SetURL(tokenServer.URL).SetMethod(tiny.Get))
+
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "502 Bad Gateway: <html><body>502 Bad Gateway</body></html>")
+}
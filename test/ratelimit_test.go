@@ -0,0 +1,115 @@
+package interview_accountapi_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer other.Close()
+
+	client := tiny.NewClient().SetRateLimiter(tiny.NewRateLimiter(20, 1))
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	// First request uses the burst, the other four wait 50ms each
+	require.True(t, time.Since(start) >= 190*time.Millisecond, time.Since(start))
+
+	// Another host has its own bucket
+	start = time.Now()
+	_, err := client.Send(client.NewRequest().SetURL(other.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	require.True(t, time.Since(start) < 40*time.Millisecond)
+}
+
+func TestRateLimiterContextDeadline(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client := tiny.NewClient().SetRateLimiter(tiny.NewRateLimiter(1, 1)).SetContext(ctx)
+
+	_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.True(t, errors.Is(err, tiny.ErrRateLimited))
+	require.True(t, time.Since(start) < 40*time.Millisecond)
+}
+
+func TestRateLimiterAdaptive(t *testing.T) {
+
+	var throttled int32 = 1
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if atomic.LoadInt32(&throttled) == 1 {
+				rw.WriteHeader(http.StatusTooManyRequests)
+			}
+		}),
+	)
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	limiter := tiny.NewRateLimiter(100, 10)
+	limiter.Adaptive = true
+	client := tiny.NewClient().SetRateLimiter(limiter)
+
+	for i := 0; i < 3; i++ {
+		response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusTooManyRequests, response.Response.StatusCode)
+	}
+	require.Equal(t, 12.5, limiter.CurrentRate(serverURL.Host))
+
+	atomic.StoreInt32(&throttled, 0)
+	_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	require.Equal(t, 17.5, limiter.CurrentRate(serverURL.Host))
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	limiter := tiny.NewRateLimiter(0, 1)
+	client := tiny.NewClient().SetRateLimiter(limiter).SetContext(ctx)
+
+	// A rate of 0 never waits for a token
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+		require.NoError(t, err)
+	}
+	require.True(t, time.Since(start) < 500*time.Millisecond, time.Since(start))
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	require.Zero(t, limiter.CurrentRate(u.Host))
+}
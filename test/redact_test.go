@@ -0,0 +1,131 @@
+package interview_accountapi_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactDebugOutput(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "session", Value: "session-secret"})
+		rw.Header().Set(tiny.ContentType, tiny.JsonContentType)
+		rw.Write([]byte(`{"data":{"attributes":{"iban":"GB33BUKB20201555555555","country":"GB"}}}`))
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := tiny.NewClient().SetLogger(logger).SetDebugMode(true).
+		SetAuthenticator(tiny.NewBearerToken("bearer-secret"))
+	client.SetRedactor(tiny.NewRedactor().AddJSONFields("data.attributes.name", "data.attributes.iban"))
+
+	body := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"name":    []string{"Samantha Holder"},
+				"iban":    "GB33BUKB20201555555555",
+				"country": "GB",
+			},
+		},
+	}
+	request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Post).
+		SetContentType(tiny.JsonContentType).
+		SetBody(body).
+		AddQueryParam("token", "query-secret").
+		AddQueryParam("page", "1")
+	_, err := client.Send(request)
+	require.NoError(t, err)
+
+	var output strings.Builder
+	for _, entry := range logger.entries {
+		output.WriteString(entry.msg)
+		if url, ok := entry.fields["url"]; ok {
+			output.WriteString(url.(string))
+		}
+	}
+	for _, secret := range []string{"bearer-secret", "query-secret", "session-secret", "Samantha Holder", "GB33BUKB20201555555555"} {
+		require.NotContains(t, output.String(), secret)
+	}
+	require.Contains(t, output.String(), tiny.RedactedValue)
+	require.Contains(t, output.String(), `"country":"GB"`)
+	require.Contains(t, output.String(), "page=1")
+}
+
+func TestRedactor(t *testing.T) {
+
+	redactor := tiny.NewRedactor().AddHeaders("X-Account-Id").AddJSONFields("data.*.iban")
+
+	header := http.Header{"Authorization": {"Basic secret"}, "X-Account-Id": {"42"}, "Accept": {"*/*"}}
+	redacted := redactor.Header(header)
+	require.Equal(t, []string{tiny.RedactedValue}, redacted["Authorization"])
+	require.Equal(t, []string{tiny.RedactedValue}, redacted["X-Account-Id"])
+	require.Equal(t, []string{"*/*"}, redacted["Accept"])
+	require.Equal(t, "Basic secret", header.Get("Authorization"))
+
+	// Arrays are walked through and long bodies are truncated
+	body := []byte(`{"data":[{"account":{"iban":"GB1"}},{"account":{"iban":"GB2"}}]}`)
+	require.Equal(t, `{"data":[{"account":{"iban":"[REDACTED]"}},{"account":{"iban":"[REDACTED]"}}]}`, redactor.Body(body))
+	require.Equal(t, `{"data":[{"account":... (58 bytes truncated)`, redactor.SetMaxBodyLength(20).Body(body))
+
+	require.Equal(t, "not json", tiny.NewRedactor().AddJSONFields("iban").Body([]byte("not json")))
+}
+
+func TestRedactErrorLog(t *testing.T) {
+
+	closed := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	closed.Close()
+
+	logger := &recordingLogger{}
+	client := tiny.NewClient().SetLogger(logger)
+	_, err := client.Send(client.NewRequest().SetURL(closed.URL).SetMethod(tiny.Get).AddQueryParam("access_token", "secret"))
+	require.Error(t, err)
+
+	// The URL inside the *url.Error message is masked like the url field
+	failed := logger.levelEntries(tiny.LevelError)
+	require.Len(t, failed, 1)
+	logged := failed[0].fields["error"].(error)
+	require.True(t, errors.Is(logged, err))
+	message := logged.Error()
+	require.NotContains(t, message, "secret")
+	require.Contains(t, message, "access_token=[REDACTED]")
+	require.NotContains(t, failed[0].fields["url"], "secret")
+}
+
+// urlSigner fails with the URL of the request in the error, like a signer of another package could
+type urlSigner struct{}
+
+func (urlSigner) Sign(httpRequest *http.Request, body []byte) error {
+	return fmt.Errorf("can't sign %s %s", httpRequest.Method, httpRequest.URL)
+}
+
+func TestRedactNonTransportErrorLog(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := tiny.NewClient().SetLogger(logger).SetSigner(urlSigner{})
+	_, err := client.Send(client.NewRequest().SetURL(server.URL+"/acc").SetMethod(tiny.Get).
+		AddQueryParam("api_key", "SUPERSECRET").AddQueryParam("page", "1"))
+	require.Error(t, err)
+
+	// Every URL in the message is masked, not only the one of a *url.Error
+	failed := logger.levelEntries(tiny.LevelError)
+	require.Len(t, failed, 1)
+	logged := failed[0].fields["error"].(error)
+	require.True(t, errors.Is(logged, err))
+	require.NotContains(t, logged.Error(), "SUPERSECRET")
+	require.Contains(t, logged.Error(), "api_key=[REDACTED]")
+
+	redactor := tiny.NewRedactor()
+	masked := redactor.Error(errors.New("moved from https://a.test/x?token=one to http://b.test/y?page=2&token=two"))
+	require.Equal(t, "moved from https://a.test/x?token=[REDACTED] to http://b.test/y?page=2&token=[REDACTED]", masked.Error())
+	plain := errors.New("no credentials in https://a.test/x?page=1")
+	require.Equal(t, plain, redactor.Error(plain))
+}
@@ -0,0 +1,143 @@
+package interview_accountapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.NotEqual(t, tiny.RedactedValue, req.Header.Get("Authorization"))
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/v1/accounts/1":
+			rw.Write([]byte(`{"id":"1","created_on":"today"}`))
+		case req.Method == http.MethodPost && req.URL.Path == "/v1/accounts":
+			body, _ := ioutil.ReadAll(req.Body)
+			rw.WriteHeader(http.StatusCreated)
+			rw.Write(body)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	records := []tiny.AuditRecord{
+		{
+			Method:         "GET",
+			URL:            "https://api.example.com/accounts/${account_id}",
+			RequestHeaders: http.Header{"Authorization": {tiny.RedactedValue}},
+			Status:         200,
+			ResponseBody:   `{"id":"1","created_on":"yesterday"}`,
+		},
+		{
+			Method:         "POST",
+			URL:            "https://api.example.com/accounts",
+			RequestHeaders: http.Header{"Content-Type": {tiny.JsonContentType}},
+			RequestBody:    `{"name":"${name}"}`,
+			Status:         201,
+			ResponseBody:   `{"name":"Samantha"}`,
+		},
+		{
+			Method: "DELETE",
+			URL:    "https://api.example.com/accounts/1?version=0",
+			Status: 204,
+		},
+	}
+	var file bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		require.NoError(t, err)
+		file.Write(append(line, '\n'))
+	}
+
+	client := tiny.NewClient().SetLogger(tiny.NopLogger)
+	replayer := tiny.NewReplayer(client, server.URL+"/v1").
+		SetRate(20).
+		SetVariable("account_id", "1").
+		SetVariable("name", "Samantha").
+		IgnoreFields("created_on")
+
+	start := time.Now()
+	report, err := replayer.Replay(context.Background(), &file)
+	require.NoError(t, err)
+	require.True(t, time.Since(start) >= 100*time.Millisecond, time.Since(start))
+
+	require.Len(t, report.Results, 3)
+	require.Equal(t, 2, report.Matched)
+	require.Equal(t, 1, report.Mismatched)
+	require.True(t, report.Results[0].Matched())
+	require.True(t, report.Results[1].Matched())
+	require.False(t, report.Results[2].StatusMatch)
+	require.Equal(t, http.StatusNotFound, report.Results[2].Status)
+	require.True(t, strings.Contains(report.String(), "line 3 DELETE https://api.example.com/accounts/1?version=0: status 404, recorded 204"), report.String())
+}
+
+func TestReplayRedactedQueryParams(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.Equal(t, url.Values{"page[number]": {"2"}}, req.URL.Query())
+		rw.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	record := tiny.AuditRecord{
+		Method:       "GET",
+		URL:          "https://api.example.com/accounts?token=" + tiny.RedactedValue + "&page%5Bnumber%5D=2",
+		Status:       200,
+		ResponseBody: `{}`,
+	}
+	line, err := json.Marshal(record)
+	require.NoError(t, err)
+
+	client := tiny.NewClient().SetLogger(tiny.NopLogger)
+	report, err := tiny.NewReplayer(client, server.URL).Replay(context.Background(), bytes.NewReader(line))
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+	require.True(t, report.Results[0].Matched(), report.String())
+}
+
+func TestReplayIncompleteRequestBody(t *testing.T) {
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	// Records written by an AuditSink which cut and masked the request bodies
+	var file bytes.Buffer
+	sink := tiny.NewAuditSink(&file, 10)
+	sink.MaxBodyLength = 24
+	recorder := tiny.NewClient().SetLogger(tiny.NopLogger).SetAuditSink(sink).
+		SetRedactor(tiny.NewRedactor().AddJSONFields("iban"))
+	response, err := recorder.Send(recorder.NewRequest().SetURL(server.URL).SetMethod(tiny.Post).
+		SetBody([]byte(strings.Repeat("x", 30))))
+	require.NoError(t, err)
+	require.NoError(t, response.Response.Body.Close())
+	response, err = recorder.Send(recorder.NewRequest().SetURL(server.URL).SetMethod(tiny.Post).
+		SetContentType(tiny.JsonContentType).SetBody(map[string]string{"iban": "GB29"}))
+	require.NoError(t, err)
+	require.NoError(t, response.Response.Body.Close())
+	require.NoError(t, sink.Close())
+	requests = 0
+
+	client := tiny.NewClient().SetLogger(tiny.NopLogger)
+	report, err := tiny.NewReplayer(client, server.URL).Replay(context.Background(), &file)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Failed)
+	require.Contains(t, report.Results[0].Err.Error(), "truncated")
+	require.Contains(t, report.Results[1].Err.Error(), "redacted")
+	require.Zero(t, requests)
+}
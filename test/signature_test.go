@@ -0,0 +1,108 @@
+package interview_accountapi_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPSignatures(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var table = []struct {
+		name   string
+		key    crypto.Signer
+		scheme tiny.SignatureScheme
+	}{
+		{"rsa-cavage", rsaKey, tiny.SignatureCavage},
+		{"rsa-rfc9421", rsaKey, tiny.SignatureRFC9421},
+		{"ecdsa-cavage", ecdsaKey, tiny.SignatureCavage},
+		{"ecdsa-rfc9421", ecdsaKey, tiny.SignatureRFC9421},
+		{"ed25519-cavage", ed25519Key, tiny.SignatureCavage},
+		{"ed25519-rfc9421", ed25519Key, tiny.SignatureRFC9421},
+	}
+
+	for _, row := range table {
+		publicKey := row.key.Public()
+		server := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				require.NoError(t, tiny.VerifyHTTPSignature(req, publicKey), row.name)
+
+				// Body is still readable after verification
+				b, _ := ioutil.ReadAll(req.Body)
+				require.Equal(t, desiredData, string(b), row.name)
+			}),
+		)
+
+		client := tiny.NewClient().SetSigner(tiny.NewHTTPSigner("test-key", row.key, row.scheme))
+		request := client.NewRequest().SetURL(server.URL+"/v1/organisation/accounts").SetMethod(tiny.Post).
+			AddQueryParam("version", "0").
+			SetBody(desiredData)
+
+		response, err := client.Send(request)
+		server.Close()
+
+		require.NoError(t, err, row.name)
+		require.Equal(t, 200, response.Response.StatusCode, row.name)
+	}
+}
+
+func TestHTTPSignatureCoversAuthorization(t *testing.T) {
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			require.NotEmpty(t, req.Header.Get("Content-Digest"))
+			require.True(t, strings.HasPrefix(req.Header.Get("Signature-Input"), `sig1=("@method" "@target-uri" "authorization" "content-digest");`))
+			require.NoError(t, tiny.VerifyHTTPSignature(req, key.Public()))
+
+			// A tampered header breaks the signature
+			req.Header.Set("Authorization", "Bearer other-token")
+			require.Error(t, tiny.VerifyHTTPSignature(req, key.Public()))
+		}),
+	)
+	defer server.Close()
+
+	signer := tiny.NewHTTPSigner("test-key", key, tiny.SignatureRFC9421).
+		SetComponents("@method", "@target-uri", "authorization", "content-digest")
+	client := tiny.NewClient().SetAuthenticator(tiny.NewBearerToken("token")).SetSigner(signer)
+
+	request := client.NewRequest().SetURL(fmt.Sprintf("%s/get", server.URL)).SetMethod(tiny.Get)
+	response, err := client.Send(request)
+
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+}
+
+func TestHTTPSignatureDigestMismatch(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	httpRequest := httptest.NewRequest("POST", "http://example.com/post", strings.NewReader(desiredData))
+	signer := tiny.NewHTTPSigner("test-key", rsaKey, tiny.SignatureCavage)
+	require.NoError(t, signer.Sign(httpRequest, []byte(desiredData)))
+	require.NoError(t, tiny.VerifyHTTPSignature(httpRequest, rsaKey.Public()))
+
+	httpRequest.Body = ioutil.NopCloser(strings.NewReader("tampered"))
+	require.Error(t, tiny.VerifyHTTPSignature(httpRequest, rsaKey.Public()))
+}
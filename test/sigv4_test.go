@@ -0,0 +1,148 @@
+package interview_accountapi_test
+
+import (
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Requests and signatures from the AWS Signature Version 4 test suite
+func TestSigV4TestSuite(t *testing.T) {
+
+	var table = []struct {
+		name      string
+		method    string
+		url       string
+		headers   map[string]string
+		body      string
+		signed    string
+		signature string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/", nil, "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/", nil, "",
+			"host;x-amz-date", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", nil, "",
+			"host;x-amz-date", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"post-x-www-form-urlencoded", "POST", "https://example.amazonaws.com/",
+			map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, "Param1=value1",
+			"content-type;host;x-amz-date", "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+		{"get-vanilla-query-order-key", "GET", "https://example.amazonaws.com/?Param1=value2&Param1=Value1", nil, "",
+			"host;x-amz-date", "eedbc4e291e521cf13422ffca22be7d2eb8146eecf653089df300a15b2382bd1"},
+		{"get-vanilla-query-order-value", "GET", "https://example.amazonaws.com/?Param1=value2&Param1=value1", nil, "",
+			"host;x-amz-date", "5772eed61e12b33fae39ee5e7012498b51d56abc0abb7c60486157bd471c4694"},
+		{"get-vanilla-query-unreserved", "GET", "https://example.amazonaws.com/?-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz=-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", nil, "",
+			"host;x-amz-date", "9c3e54bfcdf0b19771a7f523ee5669cdf59bc7cc0884027167c21bb143a40197"},
+		{"get-relative", "GET", "https://example.amazonaws.com/example/..", nil, "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-relative-relative", "GET", "https://example.amazonaws.com/example1/example2/../..", nil, "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-slash", "GET", "https://example.amazonaws.com//", nil, "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-slash-dot-slash", "GET", "https://example.amazonaws.com/./", nil, "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-slash-pointless-dot", "GET", "https://example.amazonaws.com/./example", nil, "",
+			"host;x-amz-date", "ef75d96142cf21edca26f06005da7988e4f8dc83a165a80865db7089db637ec5"},
+		{"get-slashes", "GET", "https://example.amazonaws.com//example//", nil, "",
+			"host;x-amz-date", "9a624bd73a37c9a373b5312afbebe7a714a789de108f0bdfe846570885f57e84"},
+		{"get-space", "GET", "https://example.amazonaws.com/example%20space/", nil, "",
+			"host;x-amz-date", "652487583200325589f1fba4c7e578f72c47cb61beeca81406b39ddec1366741"},
+		// Not from the test suite, a key which is a prefix of another key sorts first
+		{"query-key-prefix", "GET", "https://example.amazonaws.com/?Param1=a&Param=b", nil, "",
+			"host;x-amz-date", "efe2e317db8c14f0ae4b56b47049bdbd81da78bd82de4d709db65271c88511c5"},
+	}
+
+	for _, row := range table {
+		httpRequest, err := http.NewRequest(row.method, row.url, strings.NewReader(row.body))
+		require.NoError(t, err)
+		httpRequest.Header.Set("X-Amz-Date", "20150830T123600Z")
+		for key, value := range row.headers {
+			httpRequest.Header.Set(key, value)
+		}
+
+		signer := tiny.NewSigV4Signer("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service").SetKeepDate(true)
+		require.NoError(t, signer.Sign(httpRequest, []byte(row.body)), row.name)
+
+		expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=" + row.signed + ", Signature=" + row.signature
+		require.Equal(t, expected, httpRequest.Header.Get("Authorization"), row.name)
+	}
+}
+
+// post-sts-header-before of the AWS Signature Version 4 test suite, the session token is signed
+func TestSigV4SessionToken(t *testing.T) {
+
+	httpRequest, err := http.NewRequest("POST", "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	httpRequest.Header.Set("X-Amz-Date", "20150830T123600Z")
+
+	signer := tiny.NewSigV4Signer("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service").SetKeepDate(true).
+		SetSessionToken("AQoDYXdzEPT//////////wEXAMPLEtc764bNrC9SAPBSM22wDOk4x4HIZ8j4FZTwdQWLWsKWHGBuFqwAeMicRXmxfpSPfIeoIYRqTflfKD8YUuwthAx7mSEI/qkPpKPi/kMcGdQrmGdeehM4IC1NtBmUpp2wUE8phUZampKsburEDy0KPkyQDYwT7WZ0wq5VSXDvp75YU9HFvlRd8Tx6q6fE8YQcHNVXAkiY9q6d+xo0rKwT38xVqr7ZD0u0iPPkUL64lIZbqBAz+scqKmlzm8FDrypNC9Yjc8fPOLn9FX9KSYvKTr4rvx3iSIlTJabIQwj2ICCR/oLxBA==")
+	require.NoError(t, signer.Sign(httpRequest, nil))
+
+	require.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date;x-amz-security-token, "+
+		"Signature=85d96828115b5dc0cfc3bd16ad9e210dd772bbebba041836c64533a82be05ead", httpRequest.Header.Get("Authorization"))
+}
+
+// Example from the AWS documentation of creating a signed request for IAM
+func TestSigV4IAMExample(t *testing.T) {
+
+	httpRequest, err := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	require.NoError(t, err)
+	httpRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	httpRequest.Header.Set("X-Amz-Date", "20150830T123600Z")
+
+	signer := tiny.NewSigV4Signer("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "iam").SetKeepDate(true)
+	require.NoError(t, signer.Sign(httpRequest, nil))
+
+	require.True(t, strings.HasSuffix(httpRequest.Header.Get("Authorization"),
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"))
+}
+
+func TestSigV4FreshDate(t *testing.T) {
+
+	httpRequest, err := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	require.NoError(t, err)
+	// A request signed before, like a retried one
+	httpRequest.Header.Set("X-Amz-Date", "20150830T123600Z")
+
+	signer := tiny.NewSigV4Signer("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "iam")
+	require.NoError(t, signer.Sign(httpRequest, nil))
+
+	signed, err := time.Parse("20060102T150405Z", httpRequest.Header.Get("X-Amz-Date"))
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), signed, time.Minute)
+	require.Contains(t, httpRequest.Header.Get("Authorization"), "Credential=AKIDEXAMPLE/"+signed.Format("20060102")+"/us-east-1/iam/")
+}
+
+func TestSigV4Client(t *testing.T) {
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			authorization := req.Header.Get("Authorization")
+			require.True(t, strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=minio/"))
+			require.Contains(t, authorization, "/us-east-1/s3/aws4_request")
+			require.Contains(t, authorization, "x-amz-content-sha256;x-amz-date;x-amz-security-token")
+			require.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+			// sha256 of desiredData
+			require.Len(t, req.Header.Get("X-Amz-Content-Sha256"), 64)
+		}),
+	)
+	defer server.Close()
+
+	signer := tiny.NewSigV4Signer("minio", "minio-secret", "us-east-1", "s3").SetSessionToken("session")
+	client := tiny.NewClient().SetSigner(signer)
+
+	request := client.NewRequest().SetURL(server.URL+"/bucket/object.json").SetMethod(tiny.Put).
+		AddQueryParam("x-id", "PutObject").
+		SetBody(desiredData)
+	response, err := client.Send(request)
+
+	require.NoError(t, err)
+	require.Equal(t, 200, response.Response.StatusCode)
+}
@@ -0,0 +1,25 @@
+//go:build go1.21
+// +build go1.21
+
+package interview_accountapi_test
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+
+	var buffer bytes.Buffer
+	handler := slog.NewJSONHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := tiny.NewSlogLogger(handler)
+
+	logger.Log(tiny.LevelDebug, "dropped")
+	logger.Log(tiny.LevelError, "request failed", tiny.Field{Key: "method", Value: tiny.Get}, tiny.Field{Key: "status", Value: 500})
+
+	require.Contains(t, buffer.String(), `"level":"ERROR","msg":"request failed","method":"GET","status":500`)
+	require.NotContains(t, buffer.String(), "dropped")
+}
@@ -0,0 +1,61 @@
+package interview_accountapi_test
+
+import (
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		rw.Write([]byte("timed"))
+	}))
+	defer server.Close()
+
+	client := tiny.NewClient()
+
+	response, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	_, err = response.ReadBody()
+	require.NoError(t, err)
+
+	timings := response.Timings()
+	require.False(t, timings.ConnReused)
+	require.True(t, timings.TCPConnect > 0)
+	require.Zero(t, timings.TLSHandshake)
+	require.True(t, timings.TimeToFirstByte >= 20*time.Millisecond, timings.TimeToFirstByte)
+	require.True(t, timings.Total >= timings.TimeToFirstByte+timings.BodyTransfer)
+
+	// The second request reuses the kept alive connection
+	response, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	_, err = response.ReadBody()
+	require.NoError(t, err)
+
+	timings = response.Timings()
+	require.True(t, timings.ConnReused)
+	require.Zero(t, timings.TCPConnect)
+}
+
+func TestSentAtIsSetAtSend(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	client := tiny.NewClient().SetRateLimiter(tiny.NewRateLimiter(10, 1))
+	_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+
+	// The rate limiter makes the second request wait about 100ms before it is sent
+	start := time.Now()
+	request := client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get)
+	response, err := client.Send(request)
+	require.NoError(t, err)
+	require.True(t, request.SentAt.Sub(start) >= 80*time.Millisecond, request.SentAt.Sub(start))
+	require.True(t, response.ReceivedAt.Sub(request.SentAt) < 80*time.Millisecond)
+}
@@ -0,0 +1,58 @@
+package interview_accountapi_test
+
+import (
+	"github.com/stretchr/testify/require"
+	tiny "github.com/yusufunlu/tinyclient"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUserAgent(t *testing.T) {
+
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		userAgent = req.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	client := tiny.NewClient()
+	_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	require.Equal(t, "tinyclient/"+tiny.ClientVersion+" go/"+strings.TrimPrefix(runtime.Version(), "go"), userAgent)
+
+	// Debug mode doesn't probe the system anymore
+	client.SetDebugMode(true).SetLogger(tiny.NopLogger).SetUserAgent("import-job/2.1")
+	_, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+	require.NoError(t, err)
+	require.Equal(t, "import-job/2.1", userAgent)
+
+	// A User-Agent header of the request wins
+	_, err = client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get).SetHeader("User-Agent", "request/1.0"))
+	require.NoError(t, err)
+	require.Equal(t, "request/1.0", userAgent)
+}
+
+func TestUserAgentSystemInfo(t *testing.T) {
+
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		userAgents = append(userAgents, req.Header.Get("User-Agent"))
+	}))
+	defer server.Close()
+
+	client := tiny.NewClient().SetUserAgent("import-job/2.1").SetUserAgentSystemInfo(true)
+	for i := 0; i < 2; i++ {
+		_, err := client.Send(client.NewRequest().SetURL(server.URL).SetMethod(tiny.Get))
+		require.NoError(t, err)
+	}
+
+	// System info is probed once, parts which can't be probed are left out
+	require.Equal(t, userAgents[0], userAgents[1])
+	require.True(t, strings.HasPrefix(userAgents[0], "import-job/2.1"), userAgents[0])
+	if userAgents[0] != "import-job/2.1" {
+		require.True(t, strings.HasPrefix(userAgents[0], "import-job/2.1 (") && strings.HasSuffix(userAgents[0], ")"), userAgents[0])
+	}
+}
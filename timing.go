@@ -0,0 +1,147 @@
+package tinyclient
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings is the time spent in each phase of a request, phases which didn't happen are 0
+type Timings struct {
+	DNSLookup    time.Duration
+	TCPConnect   time.Duration
+	TLSHandshake time.Duration
+	//TimeToFirstByte is from sending the request until the first response byte
+	TimeToFirstByte time.Duration
+	//BodyTransfer is from the first response byte until the body was read
+	BodyTransfer time.Duration
+	//Total is from sending the request until the body was read, or until the response was received when it wasn't read yet
+	Total time.Duration
+	//ConnReused is true when the request was sent on a kept alive connection
+	ConnReused bool
+}
+
+// requestTrace collects the httptrace events of one request, events may come from other goroutines
+type requestTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	bodyDone     time.Time
+	connReused   bool
+}
+
+// Timings returns the timing breakdown of the request, it is empty when the response didn't come from the network
+func (response *Response) Timings() Timings {
+	if response.Request == nil || response.Request.trace == nil {
+		return Timings{}
+	}
+	return response.Request.trace.timings(response.ReceivedAt)
+}
+
+// traceRequest attaches a new requestTrace to the http request, it is called right before sending it
+func (request *Request) traceRequest() *requestTrace {
+	trace := &requestTrace{start: time.Now()}
+	ctx := httptrace.WithClientTrace(request.HttpRequest.Context(), trace.clientTrace())
+	request.HttpRequest = request.HttpRequest.WithContext(ctx)
+	request.trace = trace
+	request.SentAt = trace.start
+	return trace
+}
+
+func (trace *requestTrace) clientTrace() *httptrace.ClientTrace {
+	record := func(at *time.Time, onlyFirst bool) {
+		trace.mu.Lock()
+		if !onlyFirst || at.IsZero() {
+			*at = time.Now()
+		}
+		trace.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { record(&trace.dnsStart, false) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { record(&trace.dnsDone, false) },
+		// Several addresses may be dialed in parallel, the first dial and the first connection count
+		ConnectStart: func(network, addr string) { record(&trace.connectStart, true) },
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				record(&trace.connectDone, true)
+			}
+		},
+		TLSHandshakeStart: func() { record(&trace.tlsStart, false) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { record(&trace.tlsDone, false) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			trace.mu.Lock()
+			trace.connReused = info.Reused
+			trace.mu.Unlock()
+		},
+		GotFirstResponseByte: func() { record(&trace.firstByte, false) },
+	}
+}
+
+// body records when the response body was read to the end or closed
+func (trace *requestTrace) body(body io.ReadCloser) io.ReadCloser {
+	if body == nil || body == http.NoBody {
+		trace.done()
+		return body
+	}
+	return &tracedBody{ReadCloser: body, trace: trace}
+}
+
+func (trace *requestTrace) done() {
+	trace.mu.Lock()
+	if trace.bodyDone.IsZero() {
+		trace.bodyDone = time.Now()
+	}
+	trace.mu.Unlock()
+}
+
+func (trace *requestTrace) timings(receivedAt time.Time) Timings {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+
+	between := func(start, end time.Time) time.Duration {
+		if start.IsZero() || end.IsZero() {
+			return 0
+		}
+		return end.Sub(start)
+	}
+	end := trace.bodyDone
+	if end.IsZero() {
+		end = receivedAt
+	}
+	return Timings{
+		DNSLookup:       between(trace.dnsStart, trace.dnsDone),
+		TCPConnect:      between(trace.connectStart, trace.connectDone),
+		TLSHandshake:    between(trace.tlsStart, trace.tlsDone),
+		TimeToFirstByte: between(trace.start, trace.firstByte),
+		BodyTransfer:    between(trace.firstByte, trace.bodyDone),
+		Total:           between(trace.start, end),
+		ConnReused:      trace.connReused,
+	}
+}
+
+type tracedBody struct {
+	io.ReadCloser
+	trace *requestTrace
+}
+
+func (body *tracedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if err == io.EOF {
+		body.trace.done()
+	}
+	return n, err
+}
+
+func (body *tracedBody) Close() error {
+	body.trace.done()
+	return body.ReadCloser.Close()
+}
@@ -0,0 +1,90 @@
+package tinyclient
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/host"
+)
+
+// DefaultUserAgent is sent when neither the client nor the request sets a User-Agent
+var DefaultUserAgent = fmt.Sprintf("%s/%s go/%s", ClientName, ClientVersion, strings.TrimPrefix(runtime.Version(), "go"))
+
+// systemInfo is probed once per process because host and cpu details don't change
+var (
+	systemInfoOnce sync.Once
+	systemInfo     string
+	systemInfoErr  error
+)
+
+// SetUserAgent sets the User-Agent of every request, a User-Agent header of the request still wins
+func (client *Client) SetUserAgent(userAgent string) *Client {
+	client.userAgent = userAgent
+	return client
+}
+
+// SetUserAgentSystemInfo appends the platform, cpu model and hostname to the User-Agent
+// They are probed once and the parts which can't be probed are left out
+func (client *Client) SetUserAgentSystemInfo(enabled bool) *Client {
+	client.userAgentSystemInfo = enabled
+	if enabled {
+		if _, err := probeSystemInfo(); err != nil {
+			client.Logger.Log(LevelWarn, "system info for User-Agent is incomplete", Field{Key: "error", Value: err})
+		}
+	}
+	return client
+}
+
+// applyUserAgent sets the User-Agent unless the request or the client defaults already have one
+func (client *Client) applyUserAgent(r *Request) {
+	if r.HttpRequest.Header.Get("User-Agent") != "" {
+		return
+	}
+	userAgent := client.userAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	if client.userAgentSystemInfo {
+		if info, _ := probeSystemInfo(); info != "" {
+			userAgent += " (" + info + ")"
+		}
+	}
+	r.HttpRequest.Header.Set("User-Agent", userAgent)
+}
+
+// probeSystemInfo returns "platform; cpu model; hostname" with the parts which could be probed
+func probeSystemInfo() (string, error) {
+	systemInfoOnce.Do(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				systemInfoErr = fmt.Errorf("probing system info panicked: %v", r)
+			}
+		}()
+
+		var parts []string
+		hostStat, err := host.Info()
+		if err != nil {
+			systemInfoErr = err
+		}
+		if hostStat != nil && hostStat.Platform != "" {
+			parts = append(parts, hostStat.Platform)
+		}
+
+		cpuStat, err := cpu.Info()
+		if err != nil && systemInfoErr == nil {
+			systemInfoErr = err
+		}
+		if len(cpuStat) > 0 && cpuStat[0].ModelName != "" {
+			parts = append(parts, cpuStat[0].ModelName)
+		}
+
+		if hostStat != nil && hostStat.Hostname != "" {
+			parts = append(parts, hostStat.Hostname)
+		}
+		systemInfo = strings.Join(parts, "; ")
+	})
+	return systemInfo, systemInfoErr
+}